@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultAdminBatchSize = 100
+	maxAdminBatchSize     = 1000
+	// requestedByHeader identifies who runs an admin operation, as recorded in its audit record
+	requestedByHeader = "X-Requested-By"
+)
+
+// conceptBatchReport is a line of the progress report of an admin operation on the annotations made with a concept
+type conceptBatchReport struct {
+	Batch       int                          `json:"batch"`
+	Annotations []annotations.ConceptContent `json:"annotations"`
+	// ForwardFailures lists the content whose annotations could not be forwarded
+	ForwardFailures []string `json:"forwardFailures,omitempty"`
+}
+
+// conceptOperationSummary is the last line of the progress report of an admin operation
+type conceptOperationSummary struct {
+	Done        bool   `json:"done"`
+	Batches     int    `json:"batches"`
+	Annotations int    `json:"annotations"`
+	Content     int    `json:"content"`
+	Error       string `json:"error,omitempty"`
+	// DryRun is true when the reported annotations have only been listed, not changed
+	DryRun bool `json:"dryRun,omitempty"`
+	// AuditID is the ID of the audit record of an operation which has changed annotations
+	AuditID string `json:"auditId,omitempty"`
+}
+
+// RemapConcept moves every annotation made with a concept to another one, keeping all the annotation properties.
+// Annotations are moved in batches, streaming a line of newline-delimited JSON per batch as progress report.
+// The new annotations of each affected content are forwarded, unless the forward query parameter is false.
+func (hh *httpHandler) RemapConcept(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	oldUUID := vars["oldUUID"]
+	newUUID := vars["newUUID"]
+	if oldUUID == newUUID {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "the concept cannot be remapped to itself")
+		return
+	}
+
+	batchSize, forward, ok := adminParams(w, r)
+	if !ok {
+		return
+	}
+	requestedBy, ok := adminRequester(w, r)
+	if !ok {
+		return
+	}
+
+	// only the annotations of the lifecycles this service writes are moved, as only those can be forwarded
+	lifecycles := make([]string, 0, len(hh.lifecycleMap))
+	for lifecycle := range hh.lifecycleMap {
+		lifecycles = append(lifecycles, lifecycle)
+	}
+	sort.Strings(lifecycles)
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	audit := annotations.ConceptAudit{
+		ID:             uuid.New().String(),
+		Operation:      annotations.AuditRemap,
+		ConceptUUID:    oldUUID,
+		NewConceptUUID: newUUID,
+		RequestedBy:    requestedBy,
+		TransactionID:  tid,
+		Forward:        forward,
+	}
+	hh.log.WithTransactionID(tid).Infof("%s remapping annotations from concept %s to %s, audit record %s", requestedBy, oldUUID, newUUID, audit.ID)
+	hh.runAuditedConceptBatches(w, r, audit, func() (annotations.ConceptBatch, error) {
+		return hh.annotationsService.RemapConcept(oldUUID, newUUID, lifecycles, batchSize, audit.ID)
+	})
+}
+
+// RetireConcept deletes every annotation made with a concept by the lifecycles given as lifecycle query parameters,
+// which are required so a concept is never removed from more lifecycles than intended.
+// With the dryRun query parameter set to true, the annotations that would be deleted are listed instead.
+func (hh *httpHandler) RetireConcept(w http.ResponseWriter, r *http.Request) {
+	conceptUUID := mux.Vars(r)["conceptUUID"]
+	lifecycles := r.URL.Query()["lifecycle"]
+	if len(lifecycles) == 0 {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "at least one lifecycle is required")
+		return
+	}
+	for _, lifecycle := range lifecycles {
+		if _, ok := hh.lifecycleMap[lifecycle]; !ok {
+			writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+			return
+		}
+	}
+
+	batchSize, forward, ok := adminParams(w, r)
+	if !ok {
+		return
+	}
+	dryRun, err := boolParam(r, "dryRun", false)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "dryRun must be true or false")
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	if dryRun {
+		hh.log.WithTransactionID(tid).Infof("listing annotations of concept %s for lifecycles %v", conceptUUID, lifecycles)
+		bookmark := r.Header.Get(bookmarkHeader)
+		cursor := ""
+		last := false
+		hh.runConceptBatches(w, r, tid, false, conceptOperationSummary{DryRun: true}, func() (annotations.ConceptBatch, error) {
+			if last {
+				return annotations.ConceptBatch{}, nil
+			}
+			page, err := hh.annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, "", cursor, batchSize)
+			if err != nil {
+				return annotations.ConceptBatch{}, err
+			}
+			cursor = page.NextCursor
+			last = cursor == ""
+			return annotations.ConceptBatch{Annotations: page.Content, Bookmark: bookmark}, nil
+		})
+		return
+	}
+
+	requestedBy, ok := adminRequester(w, r)
+	if !ok {
+		return
+	}
+	audit := annotations.ConceptAudit{
+		ID:            uuid.New().String(),
+		Operation:     annotations.AuditRetire,
+		ConceptUUID:   conceptUUID,
+		Lifecycles:    lifecycles,
+		RequestedBy:   requestedBy,
+		TransactionID: tid,
+		Forward:       forward,
+	}
+	hh.log.WithTransactionID(tid).Infof("%s retiring concept %s for lifecycles %v, audit record %s", requestedBy, conceptUUID, lifecycles, audit.ID)
+	hh.runAuditedConceptBatches(w, r, audit, func() (annotations.ConceptBatch, error) {
+		return hh.annotationsService.RetireConcept(conceptUUID, lifecycles, batchSize, audit.ID)
+	})
+}
+
+// adminRequester reads who runs an admin operation changing annotations, responding with 400 when it is not given
+func adminRequester(w http.ResponseWriter, r *http.Request) (string, bool) {
+	requestedBy := strings.TrimSpace(r.Header.Get(requestedByHeader))
+	if requestedBy == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, requestedByHeader+" header required to audit the operation")
+		return "", false
+	}
+	return requestedBy, true
+}
+
+// runAuditedConceptBatches runs the batches of an admin operation changing annotations, recording it in an audit record
+// before the first batch and once it has finished. The operation is not run when it cannot be recorded.
+func (hh *httpHandler) runAuditedConceptBatches(w http.ResponseWriter, r *http.Request, audit annotations.ConceptAudit, next func() (annotations.ConceptBatch, error)) {
+	audit.Status = annotations.AuditRunning
+	if err := hh.annotationsService.WriteConceptAudit(audit); err != nil {
+		hh.log.WithTransactionID(audit.TransactionID).WithError(err).Error("failed recording admin operation")
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, fmt.Sprintf("Error recording the operation (%v)", err))
+		return
+	}
+
+	summary := hh.runConceptBatches(w, r, audit.TransactionID, audit.Forward, conceptOperationSummary{AuditID: audit.ID}, next)
+
+	audit.Status, audit.Error = annotations.AuditDone, summary.Error
+	if !summary.Done {
+		audit.Status = annotations.AuditFailed
+	}
+	if err := hh.annotationsService.WriteConceptAudit(audit); err != nil {
+		hh.log.WithTransactionID(audit.TransactionID).WithError(err).Errorf("failed recording the end of admin operation %s", audit.ID)
+	}
+}
+
+// adminParams reads the batchSize and forward query parameters, responding with 400 when they are invalid.
+// Forwarding is on unless the forward parameter is false.
+func adminParams(w http.ResponseWriter, r *http.Request) (int, bool, bool) {
+	query := r.URL.Query()
+	batchSize := defaultAdminBatchSize
+	if batchSizeParam := query.Get("batchSize"); batchSizeParam != "" {
+		var err error
+		batchSize, err = strconv.Atoi(batchSizeParam)
+		if err != nil || batchSize < 1 || batchSize > maxAdminBatchSize {
+			writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("batchSize must be a number between 1 and %d", maxAdminBatchSize))
+			return 0, false, false
+		}
+	}
+
+	forward, err := boolParam(r, "forward", true)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "forward must be true or false")
+		return 0, false, false
+	}
+	return batchSize, forward, true
+}
+
+// runConceptBatches runs batches until one returns no annotations, reporting the progress as newline-delimited JSON.
+// It returns the summary of the operation, started from the given one, which is the last line of the report.
+func (hh *httpHandler) runConceptBatches(w http.ResponseWriter, r *http.Request, tid string, forward bool, summary conceptOperationSummary, next func() (annotations.ConceptBatch, error)) conceptOperationSummary {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+
+	content := map[string]bool{}
+	for {
+		if err := r.Context().Err(); err != nil {
+			summary.Error = err.Error()
+			break
+		}
+
+		batch, err := next()
+		if err != nil {
+			hh.log.WithTransactionID(tid).WithError(err).Error("admin batch failed")
+			summary.Error = err.Error()
+			break
+		}
+		if len(batch.Annotations) == 0 {
+			summary.Done = true
+			break
+		}
+
+		summary.Batches++
+		summary.Annotations += len(batch.Annotations)
+		report := conceptBatchReport{Batch: summary.Batches, Annotations: batch.Annotations}
+		for _, ann := range batch.Annotations {
+			content[ann.UUID] = true
+		}
+		if forward {
+			report.ForwardFailures = hh.forwardConceptBatch(tid, batch)
+		}
+		hh.log.WithTransactionID(tid).Infof("batch %d returned %d annotations", report.Batch, len(report.Annotations))
+
+		if err := enc.Encode(report); err != nil {
+			hh.log.WithTransactionID(tid).WithError(err).Error("writing admin response")
+			summary.Error = err.Error()
+			return summary
+		}
+		_ = rc.Flush()
+	}
+
+	summary.Content = len(content)
+	if err := enc.Encode(summary); err != nil {
+		hh.log.WithTransactionID(tid).WithError(err).Error("writing admin response")
+	}
+	return summary
+}
+
+// forwardConceptBatch forwards the current annotations of every content and lifecycle changed by a batch,
+// returning the content that could not be forwarded
+func (hh *httpHandler) forwardConceptBatch(tid string, batch annotations.ConceptBatch) []string {
+	if hh.forwarder == nil {
+		return nil
+	}
+
+	type contentLifecycle struct{ uuid, lifecycle string }
+	seen := map[contentLifecycle]bool{}
+	var failures []string
+	for _, ann := range batch.Annotations {
+		key := contentLifecycle{ann.UUID, ann.Lifecycle}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		err := hh.forwardStored(tid, ann.UUID, ann.Lifecycle, batch.Bookmark)
+		if err != nil {
+			hh.log.WithTransactionID(tid).WithUUID(ann.UUID).WithError(err).Error("Failed to forward message to queue")
+			failures = append(failures, ann.UUID)
+		}
+	}
+	return failures
+}
+
+// forwardStored forwards the annotations currently stored for a lifecycle of a piece of content
+func (hh *httpHandler) forwardStored(tid string, uuid string, lifecycle string, bookmark string) error {
+	platformVersion, ok := hh.lifecycleMap[lifecycle]
+	if !ok {
+		return fmt.Errorf("annotationLifecycle %s not supported by this application", lifecycle)
+	}
+
+	stored, _, err := hh.annotationsService.ReadAll(uuid, bookmark, []string{lifecycle})
+	if err != nil {
+		return err
+	}
+	current := stored[lifecycle]
+	anns := current.Annotations
+	if anns == nil {
+		anns = []annotations.PayloadAnnotation{}
+	}
+	return hh.forwarder.SendMessage(tid, hh.originSystemForLifecycle(lifecycle), bookmark, platformVersion, uuid, anns, current.Publication)
+}
+
+// boolParam reads an optional boolean query parameter, which is fallback when missing
+func boolParam(r *http.Request, name string, fallback bool) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseBool(value)
+}
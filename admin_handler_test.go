@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const newConceptUUID = "b2d7c4e1-6a3f-4f0e-9d5b-7c1e8a2f3b4c"
+
+func decodeAdminReport(body string) ([]conceptBatchReport, conceptOperationSummary, error) {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	var reports []conceptBatchReport
+	for _, line := range lines[:len(lines)-1] {
+		var report conceptBatchReport
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			return nil, conceptOperationSummary{}, err
+		}
+		reports = append(reports, report)
+	}
+	var summary conceptOperationSummary
+	err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary)
+	return reports, summary, err
+}
+
+func (suite *HttpHandlerTestSuite) TestRemapConceptHandler_Success() {
+	batch := annotations.ConceptBatch{
+		Annotations: []annotations.ConceptContent{
+			{UUID: knownUUID, Predicate: "about", Lifecycle: annotationLifecycle},
+			{UUID: knownUUID, Predicate: "mentions", Lifecycle: annotationLifecycle},
+		},
+		Bookmark: bookmark,
+	}
+	var audits []annotations.ConceptAudit
+	suite.annotationsService.On("WriteConceptAudit", mock.Anything).Run(func(args mock.Arguments) {
+		audits = append(audits, args.Get(0).(annotations.ConceptAudit))
+	}).Return(nil).Twice()
+	suite.annotationsService.On("RemapConcept", conceptUUID, newConceptUUID, []string{"annotations-manual", "annotations-next-video", "annotations-pac"}, 10, mock.Anything).Return(batch, nil).Once()
+	suite.annotationsService.On("RemapConcept", conceptUUID, newConceptUUID, []string{"annotations-manual", "annotations-next-video", "annotations-pac"}, 10, mock.Anything).Return(annotations.ConceptBatch{Annotations: []annotations.ConceptContent{}}, nil).Once()
+	stored := annotations.LifecycleAnnotations{Annotations: []annotations.PayloadAnnotation{{ID: "http://api.ft.com/things/" + newConceptUUID, Predicate: "http://www.ft.com/ontology/annotation/about"}}}
+	suite.annotationsService.On("ReadAll", knownUUID, bookmark, []string{annotationLifecycle}).Return(map[string]annotations.LifecycleAnnotations{annotationLifecycle: stored}, true, nil).Once()
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, stored.Annotations, []string(nil)).Return(nil).Once()
+
+	request := newRequest("POST", fmt.Sprintf("/__admin/concepts/%s/remap/%s?batchSize=10", conceptUUID, newConceptUUID), "application/json", nil)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add(requestedByHeader, "jane.doe")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	reports, summary, err := decodeAdminReport(rec.Body.String())
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.Equal(suite.T(), []conceptBatchReport{{Batch: 1, Annotations: batch.Annotations}}, reports)
+	assert.NotEmpty(suite.T(), summary.AuditID, "Expected the ID of the audit record")
+	assert.Equal(suite.T(), conceptOperationSummary{Done: true, Batches: 1, Annotations: 2, Content: 1, AuditID: summary.AuditID}, summary)
+	suite.annotationsService.AssertCalled(suite.T(), "RemapConcept", conceptUUID, newConceptUUID, []string{"annotations-manual", "annotations-next-video", "annotations-pac"}, 10, summary.AuditID)
+	expected := annotations.ConceptAudit{
+		ID:             summary.AuditID,
+		Operation:      annotations.AuditRemap,
+		ConceptUUID:    conceptUUID,
+		NewConceptUUID: newConceptUUID,
+		RequestedBy:    "jane.doe",
+		TransactionID:  suite.tid,
+		Forward:        true,
+		Status:         annotations.AuditRunning,
+	}
+	if assert.Len(suite.T(), audits, 2) {
+		assert.Equal(suite.T(), expected, audits[0])
+		expected.Status = annotations.AuditDone
+		assert.Equal(suite.T(), expected, audits[1])
+	}
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestRemapConceptHandler_BatchFailed() {
+	suite.annotationsService.On("WriteConceptAudit", mock.MatchedBy(func(audit annotations.ConceptAudit) bool { return audit.Status == annotations.AuditRunning })).Return(nil).Once()
+	suite.annotationsService.On("WriteConceptAudit", mock.MatchedBy(func(audit annotations.ConceptAudit) bool {
+		return audit.Status == annotations.AuditFailed && audit.Error == "neo4j is down"
+	})).Return(nil).Once()
+	suite.annotationsService.On("RemapConcept", conceptUUID, newConceptUUID, mock.Anything, defaultAdminBatchSize, mock.Anything).Return(annotations.ConceptBatch{}, errors.New("neo4j is down"))
+
+	request := newRequest("POST", fmt.Sprintf("/__admin/concepts/%s/remap/%s", conceptUUID, newConceptUUID), "application/json", nil)
+	request.Header.Add(requestedByHeader, "jane.doe")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	reports, summary, err := decodeAdminReport(rec.Body.String())
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.Empty(suite.T(), reports)
+	assert.Equal(suite.T(), conceptOperationSummary{Error: "neo4j is down", AuditID: summary.AuditID}, summary)
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestRemapConceptHandler_BadRequest() {
+	for _, url := range []string{
+		fmt.Sprintf("/__admin/concepts/%s/remap/%s", conceptUUID, conceptUUID),
+		fmt.Sprintf("/__admin/concepts/%s/remap/%s?batchSize=0", conceptUUID, newConceptUUID),
+		fmt.Sprintf("/__admin/concepts/%s/remap/%s?forward=maybe", conceptUUID, newConceptUUID),
+	} {
+		request := newRequest("POST", url, "application/json", nil)
+		request.Header.Add(requestedByHeader, "jane.doe")
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+		assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code for %s", url)
+	}
+	suite.annotationsService.AssertNotCalled(suite.T(), "RemapConcept", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestRemapConceptHandler_Unaudited() {
+	request := newRequest("POST", fmt.Sprintf("/__admin/concepts/%s/remap/%s", conceptUUID, newConceptUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Expected the requester to be required")
+
+	suite.annotationsService.On("WriteConceptAudit", mock.Anything).Return(errors.New("neo4j is down")).Once()
+	request.Header.Add(requestedByHeader, "jane.doe")
+	rec = httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, rec.Code, "Expected the operation not to run without an audit record")
+	suite.annotationsService.AssertNotCalled(suite.T(), "RemapConcept", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestRetireConceptHandler_Success() {
+	lifecycles := []string{annotationLifecycle, "annotations-manual"}
+	batch := annotations.ConceptBatch{
+		Annotations: []annotations.ConceptContent{{UUID: knownUUID, Predicate: "about", Lifecycle: annotationLifecycle}},
+		Bookmark:    bookmark,
+	}
+	suite.annotationsService.On("WriteConceptAudit", mock.MatchedBy(func(audit annotations.ConceptAudit) bool {
+		return audit.Operation == annotations.AuditRetire && audit.ConceptUUID == conceptUUID && assert.ObjectsAreEqual(lifecycles, audit.Lifecycles) && audit.RequestedBy == "jane.doe"
+	})).Return(nil).Twice()
+	suite.annotationsService.On("RetireConcept", conceptUUID, lifecycles, defaultAdminBatchSize, mock.Anything).Return(batch, nil).Once()
+	suite.annotationsService.On("RetireConcept", conceptUUID, lifecycles, defaultAdminBatchSize, mock.Anything).Return(annotations.ConceptBatch{Annotations: []annotations.ConceptContent{}}, nil).Once()
+	suite.annotationsService.On("ReadAll", knownUUID, bookmark, []string{annotationLifecycle}).Return(map[string]annotations.LifecycleAnnotations{}, false, nil).Once()
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, []annotations.PayloadAnnotation{}, []string(nil)).Return(nil).Once()
+
+	request := newRequest("POST", fmt.Sprintf("/__admin/concepts/%s/retire?lifecycle=%s&lifecycle=annotations-manual", conceptUUID, annotationLifecycle), "application/json", nil)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add(requestedByHeader, "jane.doe")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	reports, summary, err := decodeAdminReport(rec.Body.String())
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.Equal(suite.T(), []conceptBatchReport{{Batch: 1, Annotations: batch.Annotations}}, reports)
+	assert.Equal(suite.T(), conceptOperationSummary{Done: true, Batches: 1, Annotations: 1, Content: 1, AuditID: summary.AuditID}, summary)
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestRetireConceptHandler_DryRun() {
+	lifecycles := []string{annotationLifecycle}
+	first := annotations.ConceptContentPage{
+		Content:    []annotations.ConceptContent{{UUID: knownUUID, Predicate: "about", Lifecycle: annotationLifecycle}},
+		NextCursor: "next",
+	}
+	last := annotations.ConceptContentPage{
+		Content: []annotations.ConceptContent{{UUID: "67890", Predicate: "mentions", Lifecycle: annotationLifecycle}},
+	}
+	suite.annotationsService.On("ReadConceptContent", conceptUUID, bookmark, lifecycles, "", "", 1).Return(first, nil).Once()
+	suite.annotationsService.On("ReadConceptContent", conceptUUID, bookmark, lifecycles, "", "next", 1).Return(last, nil).Once()
+
+	request := newRequest("POST", fmt.Sprintf("/__admin/concepts/%s/retire?lifecycle=%s&dryRun=true&batchSize=1&forward=true", conceptUUID, annotationLifecycle), "application/json", nil)
+	request.Header.Add(bookmarkHeader, bookmark)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	reports, summary, err := decodeAdminReport(rec.Body.String())
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.Equal(suite.T(), []conceptBatchReport{{Batch: 1, Annotations: first.Content}, {Batch: 2, Annotations: last.Content}}, reports)
+	assert.Equal(suite.T(), conceptOperationSummary{Done: true, Batches: 2, Annotations: 2, Content: 2, DryRun: true}, summary)
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.annotationsService.AssertNotCalled(suite.T(), "RetireConcept", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestRetireConceptHandler_BadRequest() {
+	for _, url := range []string{
+		fmt.Sprintf("/__admin/concepts/%s/retire", conceptUUID),
+		fmt.Sprintf("/__admin/concepts/%s/retire?lifecycle=annotations-unknown", conceptUUID),
+		fmt.Sprintf("/__admin/concepts/%s/retire?lifecycle=%s&dryRun=maybe", conceptUUID, annotationLifecycle),
+		fmt.Sprintf("/__admin/concepts/%s/retire?lifecycle=%s&batchSize=1001", conceptUUID, annotationLifecycle),
+	} {
+		request := newRequest("POST", url, "application/json", nil)
+		request.Header.Add(requestedByHeader, "jane.doe")
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+		assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code for %s", url)
+	}
+	suite.annotationsService.AssertNotCalled(suite.T(), "RetireConcept", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,81 @@
+package annotations
+
+import (
+	"fmt"
+
+	cmneo4j "github.com/Financial-Times/cm-neo4j-driver"
+)
+
+// Operations recorded by a ConceptAudit
+const (
+	AuditRemap  = "remap"
+	AuditRetire = "retire"
+)
+
+// Statuses of a ConceptAudit
+const (
+	AuditRunning = "running"
+	AuditDone    = "done"
+	AuditFailed  = "failed"
+)
+
+// ConceptAudit is the record of an admin operation changing the annotations made with a concept, persisted as a
+// ConceptAudit node. The content whose annotations the operation changes, and the number of annotations changed,
+// are added to the record by every batch of the operation, in the same transaction.
+type ConceptAudit struct {
+	ID             string
+	Operation      string
+	ConceptUUID    string
+	NewConceptUUID string
+	Lifecycles     []string
+	RequestedBy    string
+	TransactionID  string
+	Forward        bool
+	Status         string
+	Error          string
+}
+
+// auditBatchClause adds the content and the number of annotations changed by a batch of an admin operation, given as
+// `uuids` and `annotations`, to the ConceptAudit node with the $auditID, if there is one. It keeps the single row of the batch.
+const auditBatchClause = `OPTIONAL MATCH (audit:ConceptAudit{id:$auditID})
+	FOREACH (a IN CASE WHEN audit IS NULL THEN [] ELSE [audit] END |
+		SET a.annotations = a.annotations + size(annotations),
+			a.content = a.content + [uuid IN uuids WHERE NOT uuid IN a.content],
+			a.updated = timestamp())`
+
+// WriteConceptAudit creates or updates the record of an admin operation on the annotations made with a concept.
+// The content and the number of annotations changed are left as they have been recorded by the batches of the operation.
+func (s service) WriteConceptAudit(audit ConceptAudit) error {
+	query := &cmneo4j.Query{
+		Cypher: `MERGE (audit:ConceptAudit{id:$id})
+			ON CREATE SET audit.started = timestamp(), audit.content = [], audit.annotations = 0
+			SET audit.operation = $operation,
+				audit.conceptUUID = $conceptUUID,
+				audit.newConceptUUID = $newConceptUUID,
+				audit.lifecycles = $lifecycles,
+				audit.requestedBy = $requestedBy,
+				audit.transactionId = $transactionID,
+				audit.forward = $forward,
+				audit.status = $status,
+				audit.error = $error,
+				audit.updated = timestamp()`,
+		Params: map[string]interface{}{
+			"id":             audit.ID,
+			"operation":      audit.Operation,
+			"conceptUUID":    audit.ConceptUUID,
+			"newConceptUUID": audit.NewConceptUUID,
+			"lifecycles":     audit.Lifecycles,
+			"requestedBy":    audit.RequestedBy,
+			"transactionID":  audit.TransactionID,
+			"forward":        audit.Forward,
+			"status":         audit.Status,
+			"error":          audit.Error,
+		},
+	}
+
+	_, err := s.driver.WriteMultiple([]*cmneo4j.Query{query}, nil)
+	if err != nil {
+		return fmt.Errorf("writing the audit record %s failed: %w", audit.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package annotations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	cmneo4j "github.com/Financial-Times/cm-neo4j-driver"
+)
+
+var (
+	// errUnchanged is returned by the guard of a write which would leave the stored annotations as they are
+	errUnchanged = errors.New("annotations unchanged")
+	// errNoAnnotations is returned by the guard of a write conditional on stored annotations when there are none
+	errNoAnnotations = errors.New("no annotations")
+)
+
+// maxGuardedAttempts is how many times guarded writes are tried when concurrent writes keep failing their guards
+const maxGuardedAttempts = 3
+
+// fingerprint identifies everything a write persists for the annotations of a lifecycle: the platform version,
+// the publication and the payload of every annotation, which holds all the fields persisted on its relationship.
+// It is recorded on every relationship the write leaves, while the writes changing the annotations in any other way
+// remove it, so that a write finds the annotations unchanged when they all carry its fingerprint.
+func fingerprint(write ContentWrite) (string, error) {
+	anns, ok := write.Annotations.([]interface{})
+	if !ok {
+		return "", errors.New("error in casting annotations")
+	}
+
+	// maps are marshalled with sorted keys, so the same annotations always give the same payloads
+	payloads := make([]string, 0, len(anns))
+	for _, ann := range anns {
+		payload, err := json.Marshal(ann)
+		if err != nil {
+			return "", fmt.Errorf("fingerprinting annotations failed: %w", err)
+		}
+		payloads = append(payloads, string(payload))
+	}
+	sort.Strings(payloads)
+
+	canonical, err := json.Marshal(struct {
+		PlatformVersion string   `json:"platformVersion"`
+		Publication     []string `json:"publication"`
+		Annotations     []string `json:"annotations"`
+	}{write.PlatformVersion, sortedPublication(write.Publication), payloads})
+	if err != nil {
+		return "", fmt.Errorf("fingerprinting annotations failed: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedPublication(publication []interface{}) []string {
+	result := make([]string, 0, len(publication))
+	for _, p := range publication {
+		result = append(result, fmt.Sprint(p))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// relationships counts the annotation relationships a write leaves, one for each concept and predicate
+func relationships(write ContentWrite) (int, error) {
+	anns, ok := write.Annotations.([]interface{})
+	if !ok {
+		return 0, errors.New("error in casting annotations")
+	}
+
+	distinct := map[string]bool{}
+	for _, a := range anns {
+		ann, ok := a.(map[string]interface{})
+		if !ok {
+			return 0, errors.New("error in casting annotation")
+		}
+		id, _ := ann["id"].(string)
+		predicate, _ := ann["predicate"].(string)
+		relation, ok := AnnotationRef{ID: id, Predicate: predicate}.relation()
+		if !ok {
+			return 0, fmt.Errorf("%w: %q", ErrUnknownPredicate, predicate)
+		}
+		distinct[path.Base(id)+"|"+relation] = true
+	}
+	return len(distinct), nil
+}
+
+// versionColumn returns the version of the annotations of a lifecycle, aggregated from their relationships `rel`:
+// their number along with the highest revision recorded on them. Every write of a lifecycle records a new revision
+// of the content node on all of its annotations, as does a remap on the annotations it moves, while the other changes
+// remove annotations, so the version changes whenever the annotations do.
+const versionColumn = `toString(count(rel)) + '-' + toString(coalesce(max(rel.revision), 0)) AS version`
+
+// lifecycleState is the state of the annotations of a lifecycle for a piece of content a write is conditional on
+type lifecycleState struct {
+	Index       int    `json:"index"`
+	Annotations int    `json:"annotations"`
+	Version     string `json:"version"`
+	// Fingerprinted counts the annotations carrying the fingerprint of the write
+	Fingerprinted int    `json:"fingerprinted"`
+	LastModified  *int64 `json:"lastModified"`
+}
+
+// writeGuard is what a write of the annotations of a lifecycle for a piece of content is conditional on
+type writeGuard struct {
+	contentUUID string
+	lifecycle   string
+	// fingerprint and annotations describe a write replacing all the annotations, which is skipped when it would leave
+	// them as they are. The fingerprint is empty for the other writes.
+	fingerprint string
+	annotations int
+	// existing makes the write conditional on stored annotations, as for a deletion
+	existing bool
+	// ifMatch lists the versions the annotations must have, "*" matching any version of existing annotations.
+	// The write is not conditional on the version when it is nil.
+	ifMatch []string
+	// lastModified is when the written annotations were modified, the write being rejected when the stored ones were
+	// modified later. It is recorded as the time the annotations were last modified, unless it is zero, in which case
+	// the recorded time is left as it is.
+	lastModified time.Time
+}
+
+// newWriteGuard guards a write replacing all the annotations
+func newWriteGuard(write ContentWrite) (writeGuard, error) {
+	f, err := fingerprint(write)
+	if err != nil {
+		return writeGuard{}, err
+	}
+	annotations, err := relationships(write)
+	if err != nil {
+		return writeGuard{}, err
+	}
+	return writeGuard{
+		contentUUID:  write.ContentUUID,
+		lifecycle:    write.Lifecycle,
+		fingerprint:  f,
+		annotations:  annotations,
+		ifMatch:      write.IfMatch,
+		lastModified: write.LastModified,
+	}, nil
+}
+
+// check tells why a write should not happen given the state of the stored annotations, if it should not:
+// ErrStaleWrite, ErrPreconditionFailed, errNoAnnotations or errUnchanged
+func (g writeGuard) check(state lifecycleState) error {
+	if stale(g.lastModified, state.LastModified) {
+		return ErrStaleWrite
+	}
+	if g.ifMatch != nil && (state.Annotations == 0 || (!slices.Contains(g.ifMatch, "*") && !slices.Contains(g.ifMatch, state.Version))) {
+		return ErrPreconditionFailed
+	}
+	if g.existing && state.Annotations == 0 {
+		return errNoAnnotations
+	}
+	if g.fingerprint != "" && state.Annotations == g.annotations && state.Fingerprinted == state.Annotations {
+		return errUnchanged
+	}
+	return nil
+}
+
+// query checks the guard inside the transaction of the write, as its first query. It records a new revision on the
+// content node, which takes its write lock before the stored annotations are read, so that concurrent writes of the
+// same content are checked one after the other. It returns no row when the write should not happen, which aborts the
+// transaction with cmneo4j.ErrNoResultsFound, and records the time of the write, if it has one, otherwise.
+// The conditions are the same as the ones of check.
+func (g writeGuard) query() (*cmneo4j.Query, error) {
+	conditions := []string{}
+	if g.existing {
+		conditions = append(conditions, "annotations > 0")
+	}
+	if g.fingerprint != "" {
+		conditions = append(conditions, "NOT (annotations = $annotations AND fingerprinted = annotations)")
+	}
+	return g.cypher(conditions)
+}
+
+// unchangedQuery checks inside a transaction that the write would still leave the stored annotations as they are,
+// recording its time, if it has one, as the time they were last modified, so that older writes which would change them are rejected.
+// It returns no row when the annotations have been changed concurrently, as query does when the write should not happen.
+func (g writeGuard) unchangedQuery() (*cmneo4j.Query, error) {
+	return g.cypher([]string{"annotations = $annotations AND fingerprinted = annotations"})
+}
+
+func (g writeGuard) cypher(conditions []string) (*cmneo4j.Query, error) {
+	property, err := lastModifiedProperty(g.lifecycle)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions = append([]string{"true"}, conditions...)
+	if g.ifMatch != nil {
+		conditions = append(conditions, "annotations > 0 AND ('*' IN $ifMatch OR version IN $ifMatch)")
+	}
+	modified := ""
+	var lastModified interface{}
+	if !g.lastModified.IsZero() {
+		lastModified = g.lastModified.UnixMilli()
+		conditions = append(conditions, "coalesce(content."+property+" <= $lastModified, true)")
+		modified = setLastModified(property, "$lastModified")
+	}
+	var f interface{}
+	if g.fingerprint != "" {
+		f = g.fingerprint
+	}
+
+	var result []lifecycleState
+	return &cmneo4j.Query{
+		Cypher: `MERGE (content:Thing{uuid:$contentUUID})
+			SET content.revision = coalesce(content.revision, 0) + 1
+			WITH content
+			OPTIONAL MATCH (content)-[rel]->(:Thing)
+			WHERE rel.lifecycle = $lifecycle
+			WITH content,
+				count(rel) AS annotations,
+				` + versionColumn + `,
+				sum(CASE WHEN rel.fingerprint = $fingerprint THEN 1 ELSE 0 END) AS fingerprinted
+			WHERE ` + strings.Join(conditions, " AND ") + `
+			` + modified + `
+			RETURN annotations`,
+		Params: map[string]interface{}{
+			"contentUUID":  g.contentUUID,
+			"lifecycle":    g.lifecycle,
+			"fingerprint":  f,
+			"annotations":  g.annotations,
+			"ifMatch":      g.ifMatch,
+			"lastModified": lastModified,
+		},
+		Result: &result,
+	}, nil
+}
+
+// readStateQuery reads the state of the annotations checked by many guards of the same lifecycle, in their order.
+// It returns a row for every guard, so it never fails with cmneo4j.ErrNoResultsFound.
+func readStateQuery(annotationLifecycle string, guards []writeGuard, result *[]lifecycleState) (*cmneo4j.Query, error) {
+	property, err := lastModifiedProperty(annotationLifecycle)
+	if err != nil {
+		return nil, err
+	}
+
+	writes := make([]map[string]interface{}, 0, len(guards))
+	for i, g := range guards {
+		writes = append(writes, map[string]interface{}{
+			"index":       i,
+			"contentUUID": g.contentUUID,
+			"fingerprint": g.fingerprint,
+		})
+	}
+	return &cmneo4j.Query{
+		Cypher: `UNWIND $writes AS write
+			OPTIONAL MATCH (content:Thing{uuid:write.contentUUID})
+			OPTIONAL MATCH (content)-[rel]->(:Thing)
+			WHERE rel.lifecycle = $lifecycle
+			RETURN write.index AS index,
+				content.` + property + ` AS lastModified,
+				count(rel) AS annotations,
+				` + versionColumn + `,
+				sum(CASE WHEN rel.fingerprint = write.fingerprint THEN 1 ELSE 0 END) AS fingerprinted
+			ORDER BY index`,
+		Params: map[string]interface{}{
+			"lifecycle": annotationLifecycle,
+			"writes":    writes,
+		},
+		Result: result,
+	}, nil
+}
+
+// versionQuery reads the version of the annotations of a lifecycle for a piece of content.
+// It always returns a row, the version of a lifecycle without annotations being 0-0.
+func versionQuery(contentUUID string, annotationLifecycle string, result *[]lifecycleState) *cmneo4j.Query {
+	return &cmneo4j.Query{
+		Cypher: `OPTIONAL MATCH (content:Thing{uuid:$contentUUID})-[rel]->(:Thing)
+			WHERE rel.lifecycle = $lifecycle
+			RETURN count(rel) AS annotations, ` + versionColumn,
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"lifecycle":   annotationLifecycle,
+		},
+		Result: result,
+	}
+}
@@ -0,0 +1,84 @@
+package annotations
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ConceptContent is a piece of content annotated with a concept
+type ConceptContent struct {
+	UUID      string `json:"uuid"`
+	Predicate string `json:"predicate"`
+	Lifecycle string `json:"lifecycle"`
+}
+
+// ConceptContentPage is a page of the content annotated with a concept.
+// NextCursor is empty on the last page.
+type ConceptContentPage struct {
+	Content    []ConceptContent `json:"content"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// contentCursor is the position of an annotation relationship in the ordering used for pagination
+type contentCursor struct {
+	UUID      string `json:"uuid"`
+	Relation  string `json:"relation"`
+	Lifecycle string `json:"lifecycle"`
+}
+
+func (c contentCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (contentCursor, error) {
+	var c contentCursor
+	if cursor == "" {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// ConceptBatch is the outcome of changing a batch of the annotations made with a concept
+type ConceptBatch struct {
+	// Annotations lists the content, predicate and lifecycle of every annotation changed by the batch
+	Annotations []ConceptContent
+	Bookmark    string
+}
+
+// relationTypes are the types of the annotation relationships, in a stable order
+var relationTypes = func() []string {
+	result := make([]string, 0, len(predicates))
+	for relation := range predicates {
+		result = append(result, relation)
+	}
+	sort.Strings(result)
+	return result
+}()
+
+// conceptBatchResult is returned by queries changing a batch of annotation relationships. Being an aggregate
+// it always has a single row, which avoids cmneo4j.ErrNoResultsFound rolling back the rest of the transaction.
+type conceptBatchResult struct {
+	Annotations []ConceptContent `json:"annotations"`
+}
+
+func conceptBatch(results [][]conceptBatchResult, bookmark string) ConceptBatch {
+	batch := ConceptBatch{Annotations: []ConceptContent{}, Bookmark: bookmark}
+	for _, result := range results {
+		for _, row := range result {
+			batch.Annotations = append(batch.Annotations, row.Annotations...)
+		}
+	}
+	return batch
+}
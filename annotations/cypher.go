@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/cm-annotations-ontology/model"
 
@@ -14,18 +15,45 @@ import (
 	cmneo4j "github.com/Financial-Times/cm-neo4j-driver"
 )
 
+var (
+	// ErrUnknownPredicate is returned when an annotation predicate has no matching relationship
+	ErrUnknownPredicate = errors.New("unknown predicate")
+	// ErrInvalidCursor is returned when a pagination cursor has not been produced by this service
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrUnsupportedGroupBy is returned when counts are requested for an unknown grouping
+	ErrUnsupportedGroupBy = errors.New("unsupported groupBy")
+	// ErrStaleWrite is returned when a write is older than the last write of the annotations of its lifecycle
+	ErrStaleWrite = errors.New("annotations older than the stored ones")
+	// ErrPreconditionFailed is returned when a conditional write finds the stored annotations at another version
+	ErrPreconditionFailed = errors.New("annotations do not have the expected version")
+	// ErrConcurrentWrite is returned when concurrent writes keep changing the annotations a write is conditional on
+	ErrConcurrentWrite = errors.New("annotations kept being changed by concurrent writes")
+)
+
 // Service interface. Compatible with the baserwftapp service EXCEPT for
 // 1) the Write function, which has signature Write(thing interface{}) error...
 // 2) the DecodeJson function, which has signature DecodeJSON(*json.Decoder) (thing interface{}, identity string, err error)
 // The problem is that we have a list of things, and the uuid is for a related OTHER thing
 // TODO - move to implement a shared defined Service interface?
 type Service interface {
-	Write(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, anns interface{}) (bookmark string, err error)
-	Read(contentUUID string, bookmark string, annotationLifecycle string) (thing interface{}, found bool, err error)
-	Delete(contentUUID string, annotationLifecycle string) (found bool, bookmark string, err error)
+	Write(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, anns interface{}, lastModified time.Time, ifMatch []string) (bookmark string, changed bool, err error)
+	WriteBatch(writes []ContentWrite) (bookmark string, changed []bool, err error)
+	Read(contentUUID string, bookmark string, annotationLifecycle string, filter ReadFilter) (thing interface{}, version string, found bool, err error)
+	ReadAll(contentUUID string, bookmark string, annotationLifecycles []string) (anns map[string]LifecycleAnnotations, found bool, err error)
+	ReadMany(contentUUIDs []string, bookmark string, annotationLifecycle string) (anns map[string]LifecycleAnnotations, err error)
+	Summary(contentUUID string, bookmark string, annotationLifecycles []string) (summaries map[string]LifecycleSummary, found bool, err error)
+	Patch(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, add []interface{}, remove []AnnotationRef, ifMatch []string) (anns LifecycleAnnotations, bookmark string, err error)
+	Delete(contentUUID string, annotationLifecycle string, ifMatch []string) (found bool, bookmark string, err error)
+	DeleteAll(contentUUID string, annotationLifecycles []string) (deleted []string, bookmark string, err error)
+	ReadLifecyclePage(annotationLifecycle string, bookmark string, afterUUID string, limit int) ([]ContentAnnotations, error)
+	RemapConcept(oldConceptUUID string, newConceptUUID string, annotationLifecycles []string, limit int, auditID string) (ConceptBatch, error)
+	RetireConcept(conceptUUID string, annotationLifecycles []string, limit int, auditID string) (ConceptBatch, error)
+	WriteConceptAudit(audit ConceptAudit) error
+	ReadConceptContent(conceptUUID string, bookmark string, annotationLifecycles []string, predicate string, cursor string, limit int) (ConceptContentPage, error)
 	Check() (err error)
 	DecodeJSON(*json.Decoder) (thing interface{}, err error)
 	Count(annotationLifecycle string, bookmark string, platformVersion string) (int, error)
+	CountBy(annotationLifecycle string, bookmark string, platformVersion string, groupBy string) (map[string]int, error)
 	Initialise() error
 }
 
@@ -52,75 +80,701 @@ func (s service) DecodeJSON(dec *json.Decoder) (interface{}, error) {
 	return a, err
 }
 
-func (s service) Read(contentUUID string, bookmark string, annotationLifecycle string) (ann interface{}, found bool, err error) {
+// Read returns the annotations of a lifecycle for this content, along with their version, read in the same transaction.
+// When the filter is not empty, only the matching annotations are read, in the same shape, the version still being the
+// one of all the annotations of the lifecycle. A filter matching none of the annotations of the lifecycle reads none,
+// the annotations being found as long as the lifecycle has some.
+func (s service) Read(contentUUID string, bookmark string, annotationLifecycle string, filter ReadFilter) (ann interface{}, version string, found bool, err error) {
+	if !filter.IsEmpty() {
+		return s.readFiltered(contentUUID, bookmark, annotationLifecycle, filter)
+	}
+
 	query, results := neo4j.GetReadQuery(contentUUID, annotationLifecycle)
-	_, err = s.driver.ReadMultiple(query, []string{bookmark})
+
+	// the version is read first, as it always returns a row
+	var state []lifecycleState
+	_, err = s.driver.ReadMultiple(append([]*cmneo4j.Query{versionQuery(contentUUID, annotationLifecycle, &state)}, query...), []string{bookmark})
 	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
-		return model.Annotations{}, false, nil
+		return model.Annotations{}, "", false, nil
 	}
 	if err != nil {
-		return model.Annotations{}, false, fmt.Errorf("error executing delete queries: %w", err)
+		return model.Annotations{}, "", false, fmt.Errorf("error executing read queries: %w", err)
 	}
 
 	mappedResults := *results
 	for idx := range mappedResults {
 		mapToResponseFormat(&mappedResults[idx], s.publicAPIURL)
 	}
+	if len(state) > 0 {
+		version = state[0].Version
+	}
+	return results, version, true, nil
+}
+
+func (s service) readFiltered(contentUUID string, bookmark string, annotationLifecycle string, filter ReadFilter) (interface{}, string, bool, error) {
+	var filtered []filteredAnnotations
+	query, err := filter.query(contentUUID, annotationLifecycle, &filtered)
+	if err != nil {
+		return model.Annotations{}, "", false, err
+	}
+
+	// both queries always return a row
+	var state []lifecycleState
+	_, err = s.driver.ReadMultiple([]*cmneo4j.Query{versionQuery(contentUUID, annotationLifecycle, &state), query}, []string{bookmark})
+	if err != nil {
+		return model.Annotations{}, "", false, fmt.Errorf("error executing read queries: %w", err)
+	}
+	if len(state) == 0 || state[0].Annotations == 0 {
+		return model.Annotations{}, "", false, nil
+	}
+
+	results := []model.Annotation{}
+	if len(filtered) > 0 && filtered[0].Annotations != nil {
+		results = filtered[0].Annotations
+	}
+	for idx := range results {
+		mapToResponseFormat(&results[idx], s.publicAPIURL)
+	}
+	return &results, state[0].Version, true, nil
+}
+
+// ReadAll returns the annotations of every given lifecycle for this content, grouped by lifecycle,
+// using a single query. Lifecycles without annotations are left out of the result.
+func (s service) ReadAll(contentUUID string, bookmark string, annotationLifecycles []string) (map[string]LifecycleAnnotations, bool, error) {
+	var results []storedAnnotation
+	query := &cmneo4j.Query{
+		Cypher: `MATCH (content:Thing{uuid:$contentUUID})-[rel]->(concept:Thing)
+			WHERE rel.lifecycle IN $lifecycles
+			RETURN ` + storedAnnotationColumns,
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"lifecycles":  annotationLifecycles,
+		},
+		Result: &results,
+	}
+
+	_, err := s.driver.ReadMultiple([]*cmneo4j.Query{query}, []string{bookmark})
+	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+		return map[string]LifecycleAnnotations{}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error executing read query: %w", err)
+	}
+
+	grouped := groupByLifecycle(results, s.publicAPIURL)
+	return grouped, len(grouped) > 0, nil
+}
+
+// ReadMany returns the annotations of a lifecycle for many pieces of content with a single query, keyed by
+// content UUID. Content without annotations for the lifecycle is left out.
+func (s service) ReadMany(contentUUIDs []string, bookmark string, annotationLifecycle string) (map[string]LifecycleAnnotations, error) {
+	var results []storedAnnotation
+	query := &cmneo4j.Query{
+		Cypher: `MATCH (content:Thing)-[rel]->(concept:Thing)
+			WHERE content.uuid IN $contentUUIDs AND rel.lifecycle = $lifecycle
+			RETURN content.uuid AS contentId, ` + storedAnnotationColumns + `
+			ORDER BY contentId, conceptId, relation`,
+		Params: map[string]interface{}{
+			"contentUUIDs": contentUUIDs,
+			"lifecycle":    annotationLifecycle,
+		},
+		Result: &results,
+	}
+
+	_, err := s.driver.ReadMultiple([]*cmneo4j.Query{query}, []string{bookmark})
+	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+		return map[string]LifecycleAnnotations{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error executing read query: %w", err)
+	}
+
+	anns := map[string]LifecycleAnnotations{}
+	for _, content := range groupByContent(results, s.publicAPIURL) {
+		anns[content.UUID] = content.LifecycleAnnotations
+	}
+	return anns, nil
+}
+
+// Summary counts the annotations of every given lifecycle for a piece of content, by predicate, along with the
+// platformVersion, publication and time of the last write of each lifecycle. Lifecycles without annotations are left out.
+func (s service) Summary(contentUUID string, bookmark string, annotationLifecycles []string) (map[string]LifecycleSummary, bool, error) {
+	var results []struct {
+		Lifecycle       string   `json:"lifecycle"`
+		Relation        string   `json:"relation"`
+		Count           int      `json:"count"`
+		PlatformVersion string   `json:"platformVersion"`
+		Publication     []string `json:"publication"`
+		LastWritten     *int64   `json:"lastWritten"`
+	}
+	query := &cmneo4j.Query{
+		Cypher: `MATCH (content:Thing{uuid:$contentUUID})-[rel]->(:Thing)
+			WHERE rel.lifecycle IN $lifecycles
+			RETURN rel.lifecycle AS lifecycle,
+				type(rel) AS relation,
+				count(rel) AS count,
+				head(collect(rel.platformVersion)) AS platformVersion,
+				head(collect(rel.publication)) AS publication,
+				max(rel.lastWritten) AS lastWritten`,
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"lifecycles":  annotationLifecycles,
+		},
+		Result: &results,
+	}
 
-	return results, true, nil
+	_, err := s.driver.ReadMultiple([]*cmneo4j.Query{query}, []string{bookmark})
+	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+		return map[string]LifecycleSummary{}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("executing summary query in neo4j failed: %w", err)
+	}
+
+	summaries := map[string]LifecycleSummary{}
+	lastWritten := map[string]int64{}
+	for _, result := range results {
+		summary, ok := summaries[result.Lifecycle]
+		if !ok {
+			summary = LifecycleSummary{
+				Predicates:      map[string]int{},
+				PlatformVersion: result.PlatformVersion,
+				Publication:     result.Publication,
+			}
+		}
+		summary.Count += result.Count
+		summary.Predicates[predicates[result.Relation]] += result.Count
+		if result.LastWritten != nil && *result.LastWritten > lastWritten[result.Lifecycle] {
+			lastWritten[result.Lifecycle] = *result.LastWritten
+			summary.LastWritten = time.UnixMilli(*result.LastWritten).UTC().Format(time.RFC3339Nano)
+		}
+		summaries[result.Lifecycle] = summary
+	}
+	return summaries, len(summaries) > 0, nil
 }
 
 // Delete removes all the annotations for this content. Ignore the nodes on either end -
 // may leave nodes that are only 'things' inserted by this writer: clean up
-// as a result of this will need to happen externally if required
-func (s service) Delete(contentUUID string, annotationLifecycle string) (bool, string, error) {
-	query := neo4j.BuildDeleteQuery(contentUUID, annotationLifecycle, true)
-
-	bookmark, err := s.driver.WriteMultiple([]*cmneo4j.Query{query}, nil)
+// as a result of this will need to happen externally if required.
+// With ifMatch, nothing is deleted and ErrPreconditionFailed is returned unless the annotations have one of the versions.
+func (s service) Delete(contentUUID string, annotationLifecycle string, ifMatch []string) (bool, string, error) {
+	guard := writeGuard{contentUUID: contentUUID, lifecycle: annotationLifecycle, existing: true, ifMatch: ifMatch}
+	bookmark, err := s.writeGuarded(guard, []*cmneo4j.Query{neo4j.BuildDeleteQuery(contentUUID, annotationLifecycle, true)})
+	if errors.Is(err, errNoAnnotations) {
+		return false, "", nil
+	}
 	if err != nil {
 		return false, "", fmt.Errorf("error executing delete queries: %w", err)
 	}
+	return true, bookmark, nil
+}
+
+// DeleteAll removes the annotations of every given lifecycle for a piece of content in a single transaction,
+// returning the lifecycles that had annotations.
+func (s service) DeleteAll(contentUUID string, annotationLifecycles []string) ([]string, string, error) {
+	queries := make([]*cmneo4j.Query, 0, len(annotationLifecycles))
+	for _, lifecycle := range annotationLifecycles {
+		queries = append(queries, neo4j.BuildDeleteQuery(contentUUID, lifecycle, true))
+	}
 
-	stats, err := query.Summary()
+	bookmark, err := s.driver.WriteMultiple(queries, nil)
 	if err != nil {
-		return false, "", fmt.Errorf("error running stats on delete queries: %w", err)
+		return nil, "", fmt.Errorf("error executing delete queries: %w", err)
 	}
 
-	return stats.Counters().RelationshipsDeleted() > 0, bookmark, err
+	deleted := []string{}
+	for i, query := range queries {
+		stats, err := query.Summary()
+		if err != nil {
+			return nil, "", fmt.Errorf("error running stats on delete queries: %w", err)
+		}
+		if stats.Counters().RelationshipsDeleted() > 0 {
+			deleted = append(deleted, annotationLifecycles[i])
+		}
+	}
+	return deleted, bookmark, nil
 }
 
 // Write a set of annotations associated with a piece of content. Any annotations
-// already there will be removed
-func (s service) Write(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, anns interface{}) (string, error) {
-	if contentUUID == "" {
-		return "", errors.New("content uuid is required")
+// already there will be removed. Nothing is written when the stored annotations are
+// the same as the given ones, in which case changed is false.
+// When lastModified is set, the write fails with ErrStaleWrite if the stored annotations
+// were modified later; otherwise it is recorded as the time the annotations were last modified.
+// Writes without it leave the recorded time as it is.
+// With ifMatch, nothing is written and ErrPreconditionFailed is returned unless the annotations have one of the versions.
+func (s service) Write(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, anns interface{}, lastModified time.Time, ifMatch []string) (string, bool, error) {
+	bookmark, changed, err := s.WriteBatch([]ContentWrite{{
+		ContentUUID:     contentUUID,
+		Lifecycle:       annotationLifecycle,
+		PlatformVersion: platformVersion,
+		Publication:     publication,
+		Annotations:     anns,
+		LastModified:    lastModified,
+		IfMatch:         ifMatch,
+	}})
+	if err != nil {
+		return "", false, err
+	}
+	return bookmark, changed[0], nil
+}
+
+// WriteBatch replaces the annotations of many pieces of content in a single transaction, so either all of them are
+// written or none is, and reports which of the writes have changed the stored annotations. A write leaving the stored
+// annotations as they are writes nothing, which its guard checks inside the transaction. When a batch holds many writes
+// of the same content and lifecycle, only the most recently modified one is written, the others being reported as
+// unchanged. The batch fails with ErrStaleWrite, and nothing is written, when any of the writes is older than the
+// stored annotations, and with ErrPreconditionFailed when any of them is conditional on another version.
+func (s service) WriteBatch(writes []ContentWrite) (string, []bool, error) {
+	guards := make([]writeGuard, len(writes))
+	queries := make([][]*cmneo4j.Query, len(writes))
+	latest := map[string]int{}
+	for i, write := range writes {
+		var err error
+		guards[i], err = newWriteGuard(write)
+		if err != nil {
+			return "", nil, fmt.Errorf("content %s: %w", write.ContentUUID, err)
+		}
+		queries[i], err = writeQueries(write, guards[i].fingerprint)
+		if err != nil {
+			return "", nil, fmt.Errorf("content %s: %w", write.ContentUUID, err)
+		}
+
+		key := write.ContentUUID + "|" + write.Lifecycle
+		if j, ok := latest[key]; !ok || !write.LastModified.Before(writes[j].LastModified) {
+			latest[key] = i
+		}
+	}
+
+	for attempt := 0; attempt < maxGuardedAttempts; attempt++ {
+		states, bookmark, err := s.readStates(guards)
+		if err != nil {
+			return "", nil, err
+		}
+
+		changed := make([]bool, len(writes))
+		var batch []*cmneo4j.Query
+		for i, write := range writes {
+			if latest[write.ContentUUID+"|"+write.Lifecycle] != i {
+				// the recorded times never move backwards, so a write found stale stays stale
+				if stale(write.LastModified, states[i].LastModified) {
+					return "", nil, fmt.Errorf("content %s: %w", write.ContentUUID, ErrStaleWrite)
+				}
+				continue
+			}
+			err = guards[i].check(states[i])
+			if errors.Is(err, errUnchanged) {
+				if write.LastModified.IsZero() {
+					continue
+				}
+				// the time is still recorded, so that older writes which would change the annotations are rejected
+				query, err := guards[i].unchangedQuery()
+				if err != nil {
+					return "", nil, err
+				}
+				batch = append(batch, query)
+				continue
+			}
+			if err != nil {
+				return "", nil, fmt.Errorf("content %s: %w", write.ContentUUID, err)
+			}
+			query, err := guards[i].query()
+			if err != nil {
+				return "", nil, err
+			}
+			changed[i] = true
+			batch = append(batch, query)
+			batch = append(batch, queries[i]...)
+		}
+		if len(batch) == 0 {
+			return bookmark, changed, nil
+		}
+
+		bookmark, err = s.driver.WriteMultiple(batch, nil)
+		if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+			// a concurrent write has changed the annotations checked by a guard since they were read
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("executing write queries in neo4j failed: %w", err)
+		}
+		return bookmark, changed, nil
+	}
+	return "", nil, ErrConcurrentWrite
+}
+
+// readStates reads the state of the annotations checked by every guard, in a write transaction so that it comes from
+// the leader and reflects every write acknowledged so far. The states are returned in the order of the guards.
+func (s service) readStates(guards []writeGuard) ([]lifecycleState, string, error) {
+	var lifecycles []string
+	indexes := map[string][]int{}
+	for i, g := range guards {
+		if _, ok := indexes[g.lifecycle]; !ok {
+			lifecycles = append(lifecycles, g.lifecycle)
+		}
+		indexes[g.lifecycle] = append(indexes[g.lifecycle], i)
+	}
+
+	queries := make([]*cmneo4j.Query, 0, len(lifecycles))
+	results := make([][]lifecycleState, len(lifecycles))
+	for i, lifecycle := range lifecycles {
+		lifecycleGuards := make([]writeGuard, 0, len(indexes[lifecycle]))
+		for _, index := range indexes[lifecycle] {
+			lifecycleGuards = append(lifecycleGuards, guards[index])
+		}
+		query, err := readStateQuery(lifecycle, lifecycleGuards, &results[i])
+		if err != nil {
+			return nil, "", err
+		}
+		queries = append(queries, query)
 	}
 
-	queries := append([]*cmneo4j.Query{}, neo4j.BuildDeleteQuery(contentUUID, annotationLifecycle, false))
+	bookmark, err := s.driver.WriteMultiple(queries, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading stored annotations failed: %w", err)
+	}
+	states := make([]lifecycleState, len(guards))
+	for i, lifecycle := range lifecycles {
+		for _, state := range results[i] {
+			states[indexes[lifecycle][state.Index]] = state
+		}
+	}
+	return states, bookmark, nil
+}
 
-	annotations, ok := anns.([]interface{})
+// writeGuarded runs the queries of a write in a transaction checked by its guard, returning the reason the guard
+// has failed, if it has. The write is tried again when the guard has failed because of a concurrent write.
+func (s service) writeGuarded(guard writeGuard, queries []*cmneo4j.Query) (string, error) {
+	query, err := guard.query()
+	if err != nil {
+		return "", err
+	}
+	for attempt := 0; attempt < maxGuardedAttempts; attempt++ {
+		bookmark, err := s.driver.WriteMultiple(append([]*cmneo4j.Query{query}, queries...), nil)
+		if !errors.Is(err, cmneo4j.ErrNoResultsFound) {
+			return bookmark, err
+		}
+
+		states, _, err := s.readStates([]writeGuard{guard})
+		if err != nil {
+			return "", err
+		}
+		if err = guard.check(states[0]); err != nil {
+			return "", err
+		}
+	}
+	return "", ErrConcurrentWrite
+}
+
+// writeQueries builds the queries replacing the annotations a lifecycle has written for a piece of content,
+// recording the fingerprint of the write on all of them
+func writeQueries(write ContentWrite, fingerprint string) ([]*cmneo4j.Query, error) {
+	if write.ContentUUID == "" {
+		return nil, errors.New("content uuid is required")
+	}
+
+	queries := append([]*cmneo4j.Query{}, neo4j.BuildDeleteQuery(write.ContentUUID, write.Lifecycle, false))
+
+	annotations, ok := write.Annotations.([]interface{})
 	if !ok {
-		return "", errors.New("error in casting annotations")
+		return nil, errors.New("error in casting annotations")
 	}
 
+	written := make([]map[string]interface{}, 0, len(annotations))
 	for _, annotationToWrite := range annotations {
 		annotation, ok := annotationToWrite.(map[string]interface{})
 		if !ok {
-			return "", errors.New("error in casting annotation")
+			return nil, errors.New("error in casting annotation")
+		}
+
+		query, err := neo4j.CreateAnnotationQuery(write.ContentUUID, annotation, write.PlatformVersion, write.Lifecycle, write.Publication)
+		if err != nil {
+			return nil, fmt.Errorf("create annotation query failed: %w", err)
+		}
+		queries = append(queries, query)
+		written = append(written, annotation)
+	}
+	query, err := payloadsQuery(write.ContentUUID, write.Lifecycle, written)
+	if err != nil {
+		return nil, err
+	}
+	return append(queries, query, lastWrittenQuery(write.ContentUUID, write.Lifecycle, fingerprint)), nil
+}
+
+// lastWrittenQuery records the time of a write on every annotation of the lifecycle, in milliseconds since the epoch,
+// along with the revision of the content node recorded by the guard of the write and the fingerprint of the write.
+// An empty fingerprint, given by the writes which do not replace all the annotations, removes it.
+// It has to run after the queries writing the annotations, in the same transaction.
+func lastWrittenQuery(contentUUID string, annotationLifecycle string, fingerprint string) *cmneo4j.Query {
+	var f interface{}
+	if fingerprint != "" {
+		f = fingerprint
+	}
+	return &cmneo4j.Query{
+		Cypher: `MATCH (content:Thing{uuid:$contentUUID})-[rel]->(:Thing)
+			WHERE rel.lifecycle = $lifecycle
+			SET rel.lastWritten = timestamp(), rel.revision = content.revision, rel.fingerprint = $fingerprint`,
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"lifecycle":   annotationLifecycle,
+			"fingerprint": f,
+		},
+	}
+}
+
+// patchedQuery reads the annotations of a lifecycle at the end of a patch, in its transaction. It always returns a row,
+// as a query without results fails the write: without annotations, the row is empty and has no lifecycle.
+func patchedQuery(contentUUID string, annotationLifecycle string, result *[]storedAnnotation) *cmneo4j.Query {
+	return &cmneo4j.Query{
+		Cypher: `OPTIONAL MATCH (content:Thing{uuid:$contentUUID})-[rel]->(concept:Thing)
+			WHERE rel.lifecycle = $lifecycle
+			RETURN ` + storedAnnotationColumns,
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"lifecycle":   annotationLifecycle,
+		},
+		Result: result,
+	}
+}
+
+// Patch adds and removes individual annotations of a lifecycle in a single transaction, leaving
+// the rest of them in place. Adding an annotation that already exists replaces it.
+// The resulting set of annotations for the lifecycle is read back in the same transaction and returned.
+// With ifMatch, nothing is written and ErrPreconditionFailed is returned unless the annotations have one of the versions.
+func (s service) Patch(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, add []interface{}, remove []AnnotationRef, ifMatch []string) (LifecycleAnnotations, string, error) {
+	if contentUUID == "" {
+		return LifecycleAnnotations{}, "", errors.New("content uuid is required")
+	}
+
+	var queries []*cmneo4j.Query
+	for _, ref := range remove {
+		query, err := deleteAnnotationQuery(contentUUID, annotationLifecycle, ref)
+		if err != nil {
+			return LifecycleAnnotations{}, "", err
+		}
+		queries = append(queries, query)
+	}
+
+	added := make([]map[string]interface{}, 0, len(add))
+	for _, annotationToWrite := range add {
+		annotation, ok := annotationToWrite.(map[string]interface{})
+		if !ok {
+			return LifecycleAnnotations{}, "", errors.New("error in casting annotation")
+		}
+		added = append(added, annotation)
+
+		id, _ := annotation["id"].(string)
+		predicate, _ := annotation["predicate"].(string)
+		query, err := deleteAnnotationQuery(contentUUID, annotationLifecycle, AnnotationRef{ID: id, Predicate: predicate})
+		if err != nil {
+			return LifecycleAnnotations{}, "", err
 		}
+		queries = append(queries, query)
 
-		query, err := neo4j.CreateAnnotationQuery(contentUUID, annotation, platformVersion, annotationLifecycle, publication)
+		query, err = neo4j.CreateAnnotationQuery(contentUUID, annotation, platformVersion, annotationLifecycle, publication)
 		if err != nil {
-			return "", fmt.Errorf("create annotation query failed: %w", err)
+			return LifecycleAnnotations{}, "", fmt.Errorf("create annotation query failed: %w", err)
 		}
 		queries = append(queries, query)
 	}
+	query, err := payloadsQuery(contentUUID, annotationLifecycle, added)
+	if err != nil {
+		return LifecycleAnnotations{}, "", err
+	}
+	var patched []storedAnnotation
+	queries = append(queries, query, lastWrittenQuery(contentUUID, annotationLifecycle, ""), patchedQuery(contentUUID, annotationLifecycle, &patched))
+
+	guard := writeGuard{contentUUID: contentUUID, lifecycle: annotationLifecycle, ifMatch: ifMatch}
+	bookmark, err := s.writeGuarded(guard, queries)
+	if err != nil {
+		return LifecycleAnnotations{}, "", fmt.Errorf("executing patch queries in neo4j failed: %w", err)
+	}
+
+	result := groupByLifecycle(patched, s.publicAPIURL)[annotationLifecycle]
+	if result.Annotations == nil {
+		result.Annotations = []PayloadAnnotation{}
+	}
+	return result, bookmark, nil
+}
+
+// ReadConceptContent returns a page of the content annotated with a concept, ordered by content UUID, optionally
+// restricted to some lifecycles and a single predicate. The cursor of the returned page, if any, gives the next one.
+func (s service) ReadConceptContent(conceptUUID string, bookmark string, annotationLifecycles []string, predicate string, cursor string, limit int) (ConceptContentPage, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return ConceptContentPage{}, err
+	}
+
+	conditions := []string{
+		"rel.lifecycle IS NOT NULL",
+		`(content.uuid > $after.uuid
+			OR (content.uuid = $after.uuid AND type(rel) > $after.relation)
+			OR (content.uuid = $after.uuid AND type(rel) = $after.relation AND rel.lifecycle > $after.lifecycle))`,
+	}
+	params := map[string]interface{}{
+		"conceptUUID": conceptUUID,
+		"after": map[string]interface{}{
+			"uuid":      after.UUID,
+			"relation":  after.Relation,
+			"lifecycle": after.Lifecycle,
+		},
+		"limit": limit + 1,
+	}
+	if len(annotationLifecycles) > 0 {
+		conditions = append(conditions, "rel.lifecycle IN $lifecycles")
+		params["lifecycles"] = annotationLifecycles
+	}
+	if predicate != "" {
+		relation, ok := AnnotationRef{Predicate: predicate}.relation()
+		if !ok {
+			return ConceptContentPage{}, fmt.Errorf("%w: %q", ErrUnknownPredicate, predicate)
+		}
+		conditions = append(conditions, "type(rel) = $relation")
+		params["relation"] = relation
+	}
+
+	var results []contentCursor
+	query := &cmneo4j.Query{
+		Cypher: `MATCH (content:Thing)-[rel]->(concept:Thing{uuid:$conceptUUID})
+			WHERE ` + strings.Join(conditions, " AND ") + `
+			RETURN content.uuid AS uuid, type(rel) AS relation, rel.lifecycle AS lifecycle
+			ORDER BY uuid, relation, lifecycle
+			LIMIT $limit`,
+		Params: params,
+		Result: &results,
+	}
+
+	_, err = s.driver.ReadMultiple([]*cmneo4j.Query{query}, []string{bookmark})
+	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+		return ConceptContentPage{Content: []ConceptContent{}}, nil
+	}
+	if err != nil {
+		return ConceptContentPage{}, fmt.Errorf("executing concept content query in neo4j failed: %w", err)
+	}
+
+	page := ConceptContentPage{Content: []ConceptContent{}}
+	if len(results) > limit {
+		results = results[:limit]
+		page.NextCursor = results[limit-1].encode()
+	}
+	for _, result := range results {
+		page.Content = append(page.Content, ConceptContent{
+			UUID:      result.UUID,
+			Predicate: predicates[result.Relation],
+			Lifecycle: result.Lifecycle,
+		})
+	}
+	return page, nil
+}
+
+// ReadLifecyclePage returns the annotations a lifecycle has written for at most limit pieces of content,
+// ordered by content UUID and starting after afterUUID. An empty page means there is no more content.
+func (s service) ReadLifecyclePage(annotationLifecycle string, bookmark string, afterUUID string, limit int) ([]ContentAnnotations, error) {
+	var results []storedAnnotation
+	query := &cmneo4j.Query{
+		Cypher: `MATCH (content:Thing)-[rel]->(:Thing)
+			WHERE rel.lifecycle = $lifecycle AND content.uuid > $afterUUID
+			WITH DISTINCT content
+			ORDER BY content.uuid
+			LIMIT $limit
+			MATCH (content)-[rel]->(concept:Thing)
+			WHERE rel.lifecycle = $lifecycle
+			RETURN content.uuid AS contentId, ` + storedAnnotationColumns + `
+			ORDER BY contentId, conceptId, relation`,
+		Params: map[string]interface{}{
+			"lifecycle": annotationLifecycle,
+			"afterUUID": afterUUID,
+			"limit":     limit,
+		},
+		Result: &results,
+	}
+
+	_, err := s.driver.ReadMultiple([]*cmneo4j.Query{query}, []string{bookmark})
+	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+		return []ContentAnnotations{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("executing lifecycle page query in neo4j failed: %w", err)
+	}
+	return groupByContent(results, s.publicAPIURL), nil
+}
+
+// RemapConcept moves a batch of up to limit annotations of each type made by the given lifecycles from the old concept
+// to the new one, keeping all of their properties but the fingerprint of their write, as they no longer are what has
+// been written, and recording a new revision on them so that their version changes.
+// Annotations of other lifecycles are left with the old concept.
+// It has to be called until the returned batch is empty to move every annotation.
+// The batch is added to the audit record with the given ID, if there is one, in the same transaction.
+func (s service) RemapConcept(oldConceptUUID string, newConceptUUID string, annotationLifecycles []string, limit int, auditID string) (ConceptBatch, error) {
+	queries := make([]*cmneo4j.Query, 0, len(relationTypes))
+	results := make([][]conceptBatchResult, len(relationTypes))
+	for i, relation := range relationTypes {
+		queries = append(queries, &cmneo4j.Query{
+			Cypher: fmt.Sprintf(`MATCH (content:Thing)-[rel:%[1]s]->(:Thing{uuid:$oldUUID})
+				WHERE rel.lifecycle IN $lifecycles
+				WITH content, rel
+				LIMIT $limit
+				MERGE (concept:Thing{uuid:$newUUID})
+				MERGE (content)-[moved:%[1]s{lifecycle:rel.lifecycle}]->(concept)
+				SET content.revision = coalesce(content.revision, 0) + 1
+				SET moved = properties(rel)
+				SET moved.revision = content.revision, moved.fingerprint = null
+				DELETE rel
+				WITH collect(DISTINCT {uuid: content.uuid, predicate: $predicate, lifecycle: moved.lifecycle}) AS annotations,
+					collect(DISTINCT content.uuid) AS uuids
+				`+auditBatchClause+`
+				RETURN annotations`, relation),
+			Params: map[string]interface{}{
+				"oldUUID":    oldConceptUUID,
+				"newUUID":    newConceptUUID,
+				"lifecycles": annotationLifecycles,
+				"limit":      limit,
+				"predicate":  predicates[relation],
+				"auditID":    auditID,
+			},
+			Result: &results[i],
+		})
+	}
 
 	bookmark, err := s.driver.WriteMultiple(queries, nil)
 	if err != nil {
-		return "", fmt.Errorf("executing write queries in neo4j failed: %w", err)
+		return ConceptBatch{}, fmt.Errorf("executing remap queries in neo4j failed: %w", err)
 	}
-	return bookmark, nil
+	return conceptBatch(results, bookmark), nil
+}
+
+// RetireConcept deletes a batch of up to limit annotations of each type made with the concept by the given lifecycles.
+// It has to be called until the returned batch is empty to delete every annotation. The concept itself is kept.
+// The batch is added to the audit record with the given ID, if there is one, in the same transaction.
+func (s service) RetireConcept(conceptUUID string, annotationLifecycles []string, limit int, auditID string) (ConceptBatch, error) {
+	queries := make([]*cmneo4j.Query, 0, len(relationTypes))
+	results := make([][]conceptBatchResult, len(relationTypes))
+	for i, relation := range relationTypes {
+		queries = append(queries, &cmneo4j.Query{
+			Cypher: fmt.Sprintf(`MATCH (content:Thing)-[rel:%s]->(:Thing{uuid:$conceptUUID})
+				WHERE rel.lifecycle IN $lifecycles
+				WITH content, rel, rel.lifecycle AS lifecycle
+				LIMIT $limit
+				DELETE rel
+				WITH collect(DISTINCT {uuid: content.uuid, predicate: $predicate, lifecycle: lifecycle}) AS annotations,
+					collect(DISTINCT content.uuid) AS uuids
+				`+auditBatchClause+`
+				RETURN annotations`, relation),
+			Params: map[string]interface{}{
+				"conceptUUID": conceptUUID,
+				"lifecycles":  annotationLifecycles,
+				"limit":       limit,
+				"predicate":   predicates[relation],
+				"auditID":     auditID,
+			},
+			Result: &results[i],
+		})
+	}
+
+	bookmark, err := s.driver.WriteMultiple(queries, nil)
+	if err != nil {
+		return ConceptBatch{}, fmt.Errorf("executing retire queries in neo4j failed: %w", err)
+	}
+	return conceptBatch(results, bookmark), nil
 }
 
 // Check tests if the service can connect to neo4j by running a simple query
@@ -141,6 +795,60 @@ func (s service) Count(annotationLifecycle string, bookmark string, platformVers
 	return results[0].Count, nil
 }
 
+// CountBy counts the annotations of a lifecycle broken down by predicate, publication or platform version.
+// Grouping by platform version counts the annotations of every platform version the lifecycle has written,
+// while annotations without a publication are counted under NoPublication.
+func (s service) CountBy(annotationLifecycle string, bookmark string, platformVersion string, groupBy string) (map[string]int, error) {
+	conditions := []string{"rel.lifecycle = $lifecycle"}
+	var key string
+	switch groupBy {
+	case GroupByPredicate:
+		conditions = append(conditions, "rel.platformVersion = $platformVersion")
+		key = "WITH type(rel) AS key"
+	case GroupByPublication:
+		conditions = append(conditions, "rel.platformVersion = $platformVersion")
+		key = "UNWIND CASE WHEN size(coalesce(rel.publication, [])) = 0 THEN [$noPublication] ELSE rel.publication END AS key"
+	case GroupByPlatformVersion:
+		key = "WITH rel.platformVersion AS key"
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedGroupBy, groupBy)
+	}
+
+	var results []struct {
+		Key   string `json:"key"`
+		Count int    `json:"count"`
+	}
+	query := &cmneo4j.Query{
+		Cypher: `MATCH (:Thing)-[rel]->(:Thing)
+			WHERE ` + strings.Join(conditions, " AND ") + `
+			` + key + `
+			RETURN key, count(*) AS count`,
+		Params: map[string]interface{}{
+			"lifecycle":       annotationLifecycle,
+			"platformVersion": platformVersion,
+			"noPublication":   NoPublication,
+		},
+		Result: &results,
+	}
+
+	_, err := s.driver.ReadMultiple([]*cmneo4j.Query{query}, []string{bookmark})
+	if errors.Is(err, cmneo4j.ErrNoResultsFound) {
+		return map[string]int{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("executing count query in neo4j failed: %w", err)
+	}
+
+	counts := make(map[string]int, len(results))
+	for _, result := range results {
+		if predicate, ok := predicates[result.Key]; ok && groupBy == GroupByPredicate {
+			result.Key = predicate
+		}
+		counts[result.Key] += result.Count
+	}
+	return counts, nil
+}
+
 func (s service) Initialise() error {
 	err := s.driver.EnsureConstraints(map[string]string{
 		"Thing": "uuid",
@@ -149,6 +857,24 @@ func (s service) Initialise() error {
 	return err
 }
 
+func deleteAnnotationQuery(contentUUID string, annotationLifecycle string, ref AnnotationRef) (*cmneo4j.Query, error) {
+	relation, ok := ref.relation()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPredicate, ref.Predicate)
+	}
+
+	return &cmneo4j.Query{
+		Cypher: fmt.Sprintf(`MATCH (:Thing{uuid:$contentUUID})-[rel:%s]->(:Thing{uuid:$conceptUUID})
+			WHERE rel.lifecycle = $lifecycle
+			DELETE rel`, relation),
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"conceptUUID": ref.conceptUUID(),
+			"lifecycle":   annotationLifecycle,
+		},
+	}, nil
+}
+
 func mapToResponseFormat(ann *model.Annotation, publicAPIURL string) {
 	ann.ID = thingURL(ann.ID, publicAPIURL)
 }
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/cm-annotations-ontology/model"
 
@@ -29,6 +30,7 @@ const (
 	v2AnnotationLifecycle         = "annotations-v2"
 	v2PlatformVersion             = "v2"
 	contentUUID                   = "32b089d2-2aae-403d-be6e-877404f586cf"
+	secondContentUUID             = "e8a4b6b2-9f3c-4a8e-8b6d-2f6f0d7c1a55"
 	oldConceptUUID                = "ad28ddc7-4743-4ed3-9fad-5012b61fb919"
 	conceptUUID                   = "a7732a22-3884-4bfe-9761-fef161e41d69"
 	secondConceptUUID             = "c834adfa-10c9-4748-8a21-c08537172706"
@@ -82,7 +84,7 @@ func TestWriteFailsWhenNoConceptIDSupplied(t *testing.T) {
 		AnnotatedDate:   "2016-01-01T19:43:47.314Z",
 	}}
 
-	_, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, conceptWithoutID))
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, conceptWithoutID), time.Time{}, nil)
 	assert.Error(err, "Should have failed to write annotation")
 }
 
@@ -93,15 +95,15 @@ func TestDeleteRemovesAnnotationsButNotConceptsOrContent(t *testing.T) {
 	assert.NoError(err, "creating cypher annotations service failed")
 	annotationsToDelete := exampleConcepts(conceptUUID)
 
-	bookmark, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, annotationsToDelete))
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, annotationsToDelete), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation")
 	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, annotationsToDelete)
 
-	deleted, bookmark, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle)
+	deleted, bookmark, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle, nil)
 	assert.True(deleted, "Didn't manage to delete annotations for content uuid %s: %s", contentUUID, err)
 	assert.NoError(err, "Error deleting annotation for content uuid %, conceptUUID %s", contentUUID, conceptUUID)
 
-	anns, found, err := annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle)
+	anns, _, found, err := annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle, ReadFilter{})
 
 	assert.Equal(model.Annotations{}, anns, "Found annotation for content %s when it should have been deleted", contentUUID)
 	assert.False(found, "Found annotation for content %s when it should have been deleted", contentUUID)
@@ -123,7 +125,7 @@ func TestWriteAllValuesPresent(t *testing.T) {
 	assert.NoError(err, "creating cypher annotations service failed")
 	annotationsToWrite := exampleConcepts(conceptUUID)
 
-	bookmark, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, convertAnnotations(t, annotationsToWrite))
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, convertAnnotations(t, annotationsToWrite), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation")
 
 	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, []string{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, annotationsToWrite)
@@ -154,11 +156,11 @@ func TestWriteDoesNotRemoveExistingIsClassifiedByBrandRelationshipsWithoutLifecy
 
 	annotationsToWrite := exampleConcepts(conceptUUID)
 
-	_, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, annotationsToWrite))
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, annotationsToWrite), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation")
 	checkRelationship(t, assert, contentUUID, "v2")
 
-	deleted, _, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle)
+	deleted, _, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle, nil)
 	assert.True(deleted, "Didn't manage to delete annotations for content uuid %s", contentUUID)
 	assert.NoError(err, "Error deleting annotations for content uuid %s", contentUUID)
 
@@ -204,11 +206,11 @@ func TestWriteDoesNotRemoveExistingIsClassifiedByBrandRelationshipsWithContentLi
 
 	annotationsToWrite := exampleConcepts(conceptUUID)
 
-	_, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, annotationsToWrite))
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, annotationsToWrite), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation")
 	checkRelationship(t, assert, contentUUID, "v2")
 
-	deleted, _, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle)
+	deleted, _, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle, nil)
 	assert.True(deleted, "Didn't manage to delete annotations for content uuid %s", contentUUID)
 	assert.NoError(err, "Error deleting annotations for content uuid %s", contentUUID)
 
@@ -262,9 +264,9 @@ func TestWriteDoesRemoveExistingIsClassifiedForPACTermsAndTheirRelationships(t *
 
 	assert.NoError(driver.Write(contentQuery))
 
-	_, err = annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)))
+	_, _, err = annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation")
-	found, bookmark, err := annotationsService.Delete(contentUUID, PACAnnotationLifecycle)
+	found, bookmark, err := annotationsService.Delete(contentUUID, PACAnnotationLifecycle, nil)
 	assert.True(found, "Didn't manage to delete annotations for content uuid %s", contentUUID)
 	assert.NoError(err, "Error deleting annotations for content uuid %s", contentUUID)
 
@@ -352,7 +354,7 @@ func TestWriteAndReadMultipleAnnotations(t *testing.T) {
 		},
 	}
 
-	bookmark, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, multiConceptAnnotations))
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, multiConceptAnnotations), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation")
 
 	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, multiConceptAnnotations)
@@ -381,7 +383,7 @@ func TestNextVideoAnnotationsUpdatesAnnotations(t *testing.T) {
 	err = driver.Write(contentQuery)
 	assert.NoError(err, "Error creating test data in database.")
 
-	_, err = annotationsService.Write(contentUUID, nextVideoAnnotationsLifecycle, nextVideoPlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)))
+	_, _, err = annotationsService.Write(contentUUID, nextVideoAnnotationsLifecycle, nextVideoPlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotation.")
 
 	result := []struct {
@@ -416,19 +418,541 @@ func TestUpdateWillRemovePreviousAnnotations(t *testing.T) {
 	assert.NoError(err, "creating cypher annotations service failed")
 	oldAnnotationsToWrite := exampleConcepts(oldConceptUUID)
 
-	bookmark, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, oldAnnotationsToWrite))
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, oldAnnotationsToWrite), time.Time{}, nil)
 	assert.NoError(err, "Failed to write annotations")
 	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, oldAnnotationsToWrite)
 
 	updatedAnnotationsToWrite := exampleConcepts(conceptUUID)
 
-	bookmark, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, updatedAnnotationsToWrite))
+	bookmark, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, updatedAnnotationsToWrite), time.Time{}, nil)
 	assert.NoError(err, "Failed to write updated annotations")
 	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, updatedAnnotationsToWrite)
 
 	cleanUp(t, contentUUID, v2AnnotationLifecycle, []string{conceptUUID, oldConceptUUID})
 }
 
+func TestReadAllGroupsAnnotationsByLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write v2 annotations")
+	pacAnnotations := []interface{}{
+		map[string]interface{}{
+			"id":        getURI(secondConceptUUID),
+			"predicate": "http://www.ft.com/ontology/annotation/about",
+		},
+	}
+	bookmark, _, err := annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, pacAnnotations, time.Time{}, nil)
+	assert.NoError(err, "Failed to write PAC annotations")
+
+	anns, found, err := annotationsService.ReadAll(contentUUID, bookmark, []string{v2AnnotationLifecycle, PACAnnotationLifecycle, nextVideoAnnotationsLifecycle})
+	assert.NoError(err, "Error reading annotations for content %s", contentUUID)
+	assert.True(found, "Didn't find annotations for content %s", contentUUID)
+	assert.Len(anns, 2, "Expected annotations for the v2 and PAC lifecycles only")
+
+	v2 := anns[v2AnnotationLifecycle]
+	assert.Len(v2.Annotations, 1)
+	assert.Equal(getURI(conceptUUID), v2.Annotations[0].ID)
+	assert.Equal("mentions", v2.Annotations[0].Predicate)
+	assert.Equal(0.9, *v2.Annotations[0].RelevanceScore)
+	written, err := json.Marshal(exampleConcepts(conceptUUID)[0])
+	assert.NoError(err)
+	read, err := json.Marshal(v2.Annotations[0])
+	assert.NoError(err)
+	assert.JSONEq(string(written), string(read), "Expected the annotation as it has been written")
+
+	pac := anns[PACAnnotationLifecycle]
+	assert.Equal([]string{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, pac.Publication)
+	assert.Len(pac.Annotations, 1)
+	assert.Equal(getURI(secondConceptUUID), pac.Annotations[0].ID)
+	assert.Equal("http://www.ft.com/ontology/annotation/about", pac.Annotations[0].Predicate)
+	assert.Nil(pac.Annotations[0].RelevanceScore)
+}
+
+func TestPatchAddsAndRemovesSingleAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+
+	add := convertAnnotations(t, exampleConcepts(secondConceptUUID))
+	remove := []AnnotationRef{{ID: getURI(conceptUUID), Predicate: "mentions"}}
+	result, bookmark, err := annotationsService.Patch(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, add, remove, nil)
+	assert.NoError(err, "Failed to patch annotations")
+	assert.Len(result.Annotations, 1)
+	assert.Equal(getURI(secondConceptUUID), result.Annotations[0].ID)
+
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(secondConceptUUID))
+
+	// removing the last annotation leaves none to read back
+	result, _, err = annotationsService.Patch(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, nil, []AnnotationRef{{ID: getURI(secondConceptUUID), Predicate: "mentions"}}, nil)
+	assert.NoError(err, "Failed to patch annotations")
+	assert.Empty(result.Annotations)
+
+	_, _, err = annotationsService.Patch(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, nil, []AnnotationRef{{ID: getURI(conceptUUID), Predicate: "likes"}}, nil)
+	assert.True(errors.Is(err, ErrUnknownPredicate), "ErrUnknownPredicate is expected")
+}
+
+func TestReadConceptContentPaginates(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write v2 annotations")
+	pacAnnotations := []interface{}{
+		map[string]interface{}{
+			"id":        getURI(conceptUUID),
+			"predicate": "http://www.ft.com/ontology/annotation/about",
+		},
+	}
+	bookmark, _, err := annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, pacAnnotations, time.Time{}, nil)
+	assert.NoError(err, "Failed to write PAC annotations")
+
+	lifecycles := []string{v2AnnotationLifecycle, PACAnnotationLifecycle}
+	page, err := annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, "", "", 1)
+	assert.NoError(err, "Error reading content for concept %s", conceptUUID)
+	assert.Equal([]ConceptContent{{UUID: contentUUID, Predicate: "about", Lifecycle: PACAnnotationLifecycle}}, page.Content)
+	assert.NotEmpty(page.NextCursor, "Expected a cursor for the next page")
+
+	page, err = annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, "", page.NextCursor, 1)
+	assert.NoError(err, "Error reading content for concept %s", conceptUUID)
+	assert.Equal([]ConceptContent{{UUID: contentUUID, Predicate: "mentions", Lifecycle: v2AnnotationLifecycle}}, page.Content)
+	assert.Empty(page.NextCursor, "Expected the last page")
+
+	page, err = annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, "mentions", "", 10)
+	assert.NoError(err, "Error reading content for concept %s", conceptUUID)
+	assert.Equal([]ConceptContent{{UUID: contentUUID, Predicate: "mentions", Lifecycle: v2AnnotationLifecycle}}, page.Content)
+
+	_, err = annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, "", "not a cursor", 10)
+	assert.True(errors.Is(err, ErrInvalidCursor), "ErrInvalidCursor is expected")
+}
+
+func TestReadLifecyclePagePaginatesByContent(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	bookmark, _, err := annotationsService.Write(secondContentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+
+	page, err := annotationsService.ReadLifecyclePage(v2AnnotationLifecycle, bookmark, "", 1)
+	assert.NoError(err, "Error reading lifecycle page")
+	assert.Len(page, 1, "Expected a single piece of content")
+	first := page[0]
+	assert.NotEmpty(first.Annotations, "Expected the annotations of the content")
+
+	page, err = annotationsService.ReadLifecyclePage(v2AnnotationLifecycle, bookmark, first.UUID, 1)
+	assert.NoError(err, "Error reading lifecycle page")
+	assert.Len(page, 1, "Expected a single piece of content")
+	assert.ElementsMatch([]string{contentUUID, secondContentUUID}, []string{first.UUID, page[0].UUID})
+
+	page, err = annotationsService.ReadLifecyclePage(v2AnnotationLifecycle, bookmark, page[0].UUID, 1)
+	assert.NoError(err, "Error reading lifecycle page")
+	assert.Empty(page, "Expected no more content")
+}
+
+func TestWriteBatchWritesEveryContent(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	bookmark, changed, err := annotationsService.WriteBatch([]ContentWrite{
+		{ContentUUID: contentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: convertAnnotations(t, exampleConcepts(conceptUUID))},
+		{ContentUUID: secondContentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: convertAnnotations(t, exampleConcepts(secondConceptUUID))},
+	})
+	assert.NoError(err, "Failed to write batch")
+	assert.Equal([]bool{true, true}, changed)
+
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(conceptUUID))
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, secondContentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(secondConceptUUID))
+
+	_, _, err = annotationsService.WriteBatch([]ContentWrite{
+		{ContentUUID: contentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: []interface{}{}},
+		{ContentUUID: "", Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: []interface{}{}},
+	})
+	assert.Error(err, "Expected a batch with an invalid write to fail")
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(conceptUUID))
+}
+
+func TestCountByGroupsAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	pacAnnotations := []interface{}{
+		map[string]interface{}{"id": getURI(conceptUUID), "predicate": "http://www.ft.com/ontology/annotation/about"},
+		map[string]interface{}{"id": getURI(secondConceptUUID), "predicate": "http://www.ft.com/ontology/annotation/mentions"},
+	}
+	_, _, err = annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, pacAnnotations, time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	bookmark, _, err := annotationsService.Write(secondContentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, pacAnnotations[:1], time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+
+	counts, err := annotationsService.CountBy(PACAnnotationLifecycle, bookmark, PACPlatformVersion, GroupByPredicate)
+	assert.NoError(err, "Error counting annotations by predicate")
+	assert.Equal(map[string]int{"about": 2, "mentions": 1}, counts)
+
+	counts, err = annotationsService.CountBy(PACAnnotationLifecycle, bookmark, PACPlatformVersion, GroupByPublication)
+	assert.NoError(err, "Error counting annotations by publication")
+	assert.Equal(map[string]int{"8e6c705e-1132-42a2-8db0-c295e29e8658": 2, NoPublication: 1}, counts)
+
+	counts, err = annotationsService.CountBy(PACAnnotationLifecycle, bookmark, PACPlatformVersion, GroupByPlatformVersion)
+	assert.NoError(err, "Error counting annotations by platform version")
+	assert.Equal(map[string]int{PACPlatformVersion: 3}, counts)
+
+	_, err = annotationsService.CountBy(PACAnnotationLifecycle, bookmark, PACPlatformVersion, "concept")
+	assert.True(errors.Is(err, ErrUnsupportedGroupBy), "ErrUnsupportedGroupBy is expected")
+}
+
+func TestWriteSkipsUnchangedAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	publication := []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}
+	_, changed, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, publication, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected the first write to change the stored annotations")
+
+	_, changed, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, publication, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.False(changed, "Expected writing the same annotations to be skipped")
+
+	_, changed, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected a different publication to change the stored annotations")
+
+	bookmark, changed, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected different annotations to change the stored annotations")
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(secondConceptUUID))
+
+	// every persisted field counts, down to the payload and the annotated date epoch
+	anns := convertAnnotations(t, exampleConcepts(secondConceptUUID))
+	anns[0].(map[string]interface{})["prefLabel"] = "another prefLabel"
+	_, changed, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, anns, time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected a different payload to change the stored annotations")
+	anns[0].(map[string]interface{})["annotatedDateEpoch"] = 1451677427
+	_, changed, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, anns, time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected a different annotated date epoch to change the stored annotations")
+
+	// batches compare every write with the stored annotations too
+	_, batchChanged, err := annotationsService.WriteBatch([]ContentWrite{
+		{ContentUUID: contentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: anns},
+		{ContentUUID: secondContentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: anns},
+	})
+	assert.NoError(err, "Failed to write batch")
+	assert.Equal([]bool{false, true}, batchChanged)
+
+	// annotations changed in any other way are no longer what has been written
+	_, _, err = annotationsService.Patch(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, nil, []AnnotationRef{{ID: getURI(conceptUUID), Predicate: "mentions"}}, nil)
+	assert.NoError(err, "Failed to patch annotations")
+	_, changed, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, anns, time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected patched annotations to be written again")
+}
+
+func TestWriteChecksTheVersionOfTheAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, []string{"*"})
+	assert.True(errors.Is(err, ErrPreconditionFailed), "ErrPreconditionFailed is expected without stored annotations")
+
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	_, version, found, err := annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle, ReadFilter{})
+	assert.NoError(err, "Failed to read annotations")
+	assert.True(found, "Expected annotations to be found")
+	assert.NotEmpty(version, "Expected the annotations to have a version")
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), time.Time{}, []string{"stale"})
+	assert.True(errors.Is(err, ErrPreconditionFailed), "ErrPreconditionFailed is expected for another version")
+	_, _, err = annotationsService.Patch(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, nil, []AnnotationRef{{ID: getURI(conceptUUID), Predicate: "mentions"}}, []string{"stale"})
+	assert.True(errors.Is(err, ErrPreconditionFailed), "ErrPreconditionFailed is expected for another version")
+	_, _, err = annotationsService.Delete(contentUUID, v2AnnotationLifecycle, []string{"stale"})
+	assert.True(errors.Is(err, ErrPreconditionFailed), "ErrPreconditionFailed is expected for another version")
+
+	// a write conditional on the version changes it, so the same version cannot be used twice
+	bookmark, changed, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), time.Time{}, []string{version})
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected annotations to be written")
+	_, newVersion, _, err := annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle, ReadFilter{})
+	assert.NoError(err, "Failed to read annotations")
+	assert.NotEqual(version, newVersion, "Expected the version to change")
+	_, _, err = annotationsService.Delete(contentUUID, v2AnnotationLifecycle, []string{version})
+	assert.True(errors.Is(err, ErrPreconditionFailed), "ErrPreconditionFailed is expected for the previous version")
+
+	deleted, _, err := annotationsService.Delete(contentUUID, v2AnnotationLifecycle, []string{newVersion})
+	assert.NoError(err, "Failed to delete annotations")
+	assert.True(deleted, "Expected annotations to be deleted")
+}
+
+func TestWriteRejectsStaleAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	published := time.Now().Add(-24 * time.Hour).Truncate(time.Millisecond)
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), published, nil)
+	assert.NoError(err, "Failed to write annotations")
+
+	_, changed, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), published.Add(-time.Minute), nil)
+	assert.True(errors.Is(err, ErrStaleWrite), "ErrStaleWrite is expected")
+	assert.False(changed, "Expected stale annotations not to be written")
+
+	// unchanged annotations still move the last modified time forward
+	_, changed, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), published.Add(time.Hour), nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.False(changed, "Expected writing the same annotations to be skipped")
+	_, _, err = annotationsService.WriteBatch([]ContentWrite{
+		{ContentUUID: contentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: convertAnnotations(t, exampleConcepts(secondConceptUUID)), LastModified: published.Add(time.Minute)},
+		{ContentUUID: secondContentUUID, Lifecycle: v2AnnotationLifecycle, PlatformVersion: v2PlatformVersion, Annotations: convertAnnotations(t, exampleConcepts(secondConceptUUID)), LastModified: published},
+	})
+	assert.True(errors.Is(err, ErrStaleWrite), "ErrStaleWrite is expected for a batch holding a stale write")
+
+	// writes without a time are always applied, even to an empty lifecycle, and leave the recorded time as it is
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, []interface{}{}, time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), published.Add(30*time.Minute), nil)
+	assert.True(errors.Is(err, ErrStaleWrite), "ErrStaleWrite is expected for a write older than the time recorded before")
+
+	bookmark, changed, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), published.Add(2*time.Hour), nil)
+	assert.NoError(err, "Failed to write annotations")
+	assert.True(changed, "Expected newer annotations to be written")
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(secondConceptUUID))
+}
+
+func TestWritesWithoutATimeLeaveTheRecordedTime(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	published := time.Now().Add(-24 * time.Hour).Truncate(time.Millisecond)
+	write := func(lastModified time.Time) error {
+		_, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), lastModified, nil)
+		return err
+	}
+	assert.NoError(write(published), "Failed to write annotations")
+
+	_, _, err = annotationsService.Patch(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), nil, nil)
+	assert.NoError(err, "Failed to patch annotations")
+	_, _, err = annotationsService.Delete(contentUUID, v2AnnotationLifecycle, nil)
+	assert.NoError(err, "Failed to delete annotations")
+	assert.NoError(write(time.Time{}), "Failed to write annotations")
+	_, err = annotationsService.RetireConcept(conceptUUID, []string{v2AnnotationLifecycle}, 10, "")
+	assert.NoError(err, "Failed to retire concept")
+	assert.NoError(write(time.Time{}), "Failed to write annotations")
+	_, _, err = annotationsService.DeleteAll(contentUUID, []string{v2AnnotationLifecycle})
+	assert.NoError(err, "Failed to delete annotations")
+
+	assert.True(errors.Is(write(published.Add(-time.Minute)), ErrStaleWrite), "ErrStaleWrite is expected for a write older than the last one with a time")
+	assert.NoError(write(published.Add(time.Minute)), "Expected a write newer than the last one with a time to be applied")
+}
+
+func TestDeleteAllRemovesEveryLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	_, _, err = annotationsService.Write(contentUUID, nextVideoAnnotationsLifecycle, nextVideoPlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+
+	deleted, bookmark, err := annotationsService.DeleteAll(contentUUID, []string{nextVideoAnnotationsLifecycle, PACAnnotationLifecycle, v2AnnotationLifecycle})
+	assert.NoError(err, "Error deleting annotations for content %s", contentUUID)
+	assert.Equal([]string{nextVideoAnnotationsLifecycle, v2AnnotationLifecycle}, deleted)
+
+	anns, found, err := annotationsService.ReadAll(contentUUID, bookmark, []string{nextVideoAnnotationsLifecycle, PACAnnotationLifecycle, v2AnnotationLifecycle})
+	assert.NoError(err, "Error reading annotations for content %s", contentUUID)
+	assert.False(found, "Found annotations for content %s when they should have been deleted", contentUUID)
+	assert.Empty(anns)
+	checkNodeIsStillPresent(contentUUID, t)
+}
+
+func TestRemapConceptMovesAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write v2 annotations")
+	pacAnnotations := []interface{}{
+		map[string]interface{}{
+			"id":        getURI(conceptUUID),
+			"predicate": "http://www.ft.com/ontology/annotation/about",
+		},
+	}
+	_, _, err = annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, pacAnnotations, time.Time{}, nil)
+	assert.NoError(err, "Failed to write PAC annotations")
+
+	var moved []ConceptContent
+	var bookmark string
+	for {
+		batch, err := annotationsService.RemapConcept(conceptUUID, secondConceptUUID, []string{v2AnnotationLifecycle, PACAnnotationLifecycle}, 1, "")
+		assert.NoError(err, "Error remapping concept %s", conceptUUID)
+		if err != nil || len(batch.Annotations) == 0 {
+			break
+		}
+		assert.LessOrEqual(len(batch.Annotations), len(relationTypes), "Expected at most one annotation per relationship type")
+		moved = append(moved, batch.Annotations...)
+		bookmark = batch.Bookmark
+	}
+	assert.ElementsMatch([]ConceptContent{
+		{UUID: contentUUID, Predicate: "about", Lifecycle: PACAnnotationLifecycle},
+		{UUID: contentUUID, Predicate: "mentions", Lifecycle: v2AnnotationLifecycle},
+	}, moved)
+
+	lifecycles := []string{v2AnnotationLifecycle, PACAnnotationLifecycle}
+	page, err := annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, "", "", 10)
+	assert.NoError(err, "Error reading content for concept %s", conceptUUID)
+	assert.Empty(page.Content, "Expected no annotations left with concept %s", conceptUUID)
+
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(secondConceptUUID))
+
+	anns, _, err := annotationsService.ReadAll(contentUUID, bookmark, []string{v2AnnotationLifecycle})
+	assert.NoError(err, "Error reading annotations for content %s", contentUUID)
+	if assert.Len(anns[v2AnnotationLifecycle].Annotations, 1) {
+		var remapped map[string]interface{}
+		data, err := json.Marshal(anns[v2AnnotationLifecycle].Annotations[0])
+		assert.NoError(err)
+		assert.NoError(json.Unmarshal(data, &remapped))
+		assert.Equal(getURI(secondConceptUUID), remapped["id"])
+		assert.Equal("mentions", remapped["predicate"])
+		assert.NotContains(remapped, "prefLabel", "Expected no details of the old concept")
+		assert.NotContains(remapped, "types", "Expected no details of the old concept")
+	}
+}
+
+func TestRemapConceptLeavesUnmappedLifecycles(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write v2 annotations")
+	_, _, err = annotationsService.Write(secondContentUUID, nextVideoAnnotationsLifecycle, nextVideoPlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write next-video annotations")
+
+	var moved []ConceptContent
+	var bookmark string
+	for {
+		batch, err := annotationsService.RemapConcept(conceptUUID, secondConceptUUID, []string{v2AnnotationLifecycle, PACAnnotationLifecycle}, 10, "")
+		assert.NoError(err, "Error remapping concept %s", conceptUUID)
+		if err != nil || len(batch.Annotations) == 0 {
+			break
+		}
+		moved = append(moved, batch.Annotations...)
+		bookmark = batch.Bookmark
+	}
+	assert.ElementsMatch([]ConceptContent{
+		{UUID: contentUUID, Predicate: "mentions", Lifecycle: v2AnnotationLifecycle},
+	}, moved, "Expected only the annotations of the given lifecycles to be moved")
+
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, contentUUID, v2AnnotationLifecycle, bookmark, nil, exampleConcepts(secondConceptUUID))
+	readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t, annotationsService, secondContentUUID, nextVideoAnnotationsLifecycle, bookmark, nil, exampleConcepts(conceptUUID))
+}
+
+func TestRetireConceptDeletesSelectedLifecycles(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+	defer cleanDB(t, assert)
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write v2 annotations")
+	pacAnnotations := []interface{}{
+		map[string]interface{}{
+			"id":        getURI(conceptUUID),
+			"predicate": "http://www.ft.com/ontology/annotation/about",
+		},
+	}
+	_, _, err = annotationsService.Write(secondContentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, pacAnnotations, time.Time{}, nil)
+	assert.NoError(err, "Failed to write PAC annotations")
+	_, _, err = annotationsService.Write(contentUUID, PACAnnotationLifecycle, PACPlatformVersion, nil, pacAnnotations, time.Time{}, nil)
+	assert.NoError(err, "Failed to write PAC annotations")
+
+	audit := ConceptAudit{ID: "retire-" + conceptUUID, Operation: AuditRetire, ConceptUUID: conceptUUID, Lifecycles: []string{PACAnnotationLifecycle}, RequestedBy: "test", Status: AuditRunning}
+	assert.NoError(annotationsService.WriteConceptAudit(audit), "Error writing the audit record")
+
+	var retired []ConceptContent
+	var bookmark string
+	for {
+		batch, err := annotationsService.RetireConcept(conceptUUID, []string{PACAnnotationLifecycle}, 1, audit.ID)
+		assert.NoError(err, "Error retiring concept %s", conceptUUID)
+		if err != nil || len(batch.Annotations) == 0 {
+			break
+		}
+		retired = append(retired, batch.Annotations...)
+		bookmark = batch.Bookmark
+	}
+	assert.ElementsMatch([]ConceptContent{
+		{UUID: contentUUID, Predicate: "about", Lifecycle: PACAnnotationLifecycle},
+		{UUID: secondContentUUID, Predicate: "about", Lifecycle: PACAnnotationLifecycle},
+	}, retired)
+
+	page, err := annotationsService.ReadConceptContent(conceptUUID, bookmark, []string{v2AnnotationLifecycle, PACAnnotationLifecycle}, "", "", 10)
+	assert.NoError(err, "Error reading content for concept %s", conceptUUID)
+	assert.Equal([]ConceptContent{{UUID: contentUUID, Predicate: "mentions", Lifecycle: v2AnnotationLifecycle}}, page.Content)
+
+	var recorded []struct {
+		RequestedBy string   `json:"requestedBy"`
+		Content     []string `json:"content"`
+		Annotations int      `json:"annotations"`
+	}
+	err = driver.Read(&cmneo4j.Query{
+		Cypher: `MATCH (audit:ConceptAudit{id:$id})
+			RETURN audit.requestedBy AS requestedBy, audit.content AS content, audit.annotations AS annotations`,
+		Params: map[string]interface{}{"id": audit.ID},
+		Result: &recorded,
+	})
+	assert.NoError(err, "Error reading the audit record")
+	if assert.Len(recorded, 1) {
+		assert.Equal("test", recorded[0].RequestedBy)
+		assert.ElementsMatch([]string{contentUUID, secondContentUUID}, recorded[0].Content)
+		assert.Equal(2, recorded[0].Annotations)
+	}
+	checkNodeIsStillPresent(conceptUUID, t)
+}
+
 func getNeo4jDriver(t *testing.T) *cmneo4j.Driver {
 	t.Helper()
 
@@ -448,7 +972,7 @@ func getNeo4jDriver(t *testing.T) *cmneo4j.Driver {
 // nolint:all
 func readAnnotationsForContentUUIDAndCheckKeyFieldsMatch(t *testing.T, svc Service, contentUUID, annotationLifecycle, bookmark string, publication []string, expectedAnnotations []model.Annotation) {
 	assert := assert.New(t)
-	storedThings, found, err := svc.Read(contentUUID, bookmark, annotationLifecycle)
+	storedThings, _, found, err := svc.Read(contentUUID, bookmark, annotationLifecycle, ReadFilter{})
 	storedAnnotations := storedThings.(*[]model.Annotation)
 
 	assert.NoError(err, "Error finding annotations for contentUUID %s", contentUUID)
@@ -521,7 +1045,7 @@ func cleanUp(t *testing.T, contentUUID string, annotationLifecycle string, conce
 	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
 	assert.NoError(err, "creating cypher annotations service failed")
 
-	found, _, err := annotationsService.Delete(contentUUID, annotationLifecycle)
+	found, _, err := annotationsService.Delete(contentUUID, annotationLifecycle, nil)
 	assert.True(found, "Didn't manage to delete annotations for content uuid %s", contentUUID)
 	assert.NoError(err, "Error deleting annotations for content uuid %s", contentUUID)
 
@@ -544,6 +1068,12 @@ func cleanDB(t *testing.T, assert *assert.Assertions) {
 				"contentUUID": contentUUID,
 			},
 		},
+		{
+			Cypher: "MATCH (mc:Thing {uuid: $secondContentUUID}) DETACH DELETE mc",
+			Params: map[string]interface{}{
+				"secondContentUUID": secondContentUUID,
+			},
+		},
 		{
 			Cypher: "MATCH (fc:Thing {uuid: $conceptUUID}) DETACH DELETE fc",
 			Params: map[string]interface{}{
@@ -568,6 +1098,12 @@ func cleanDB(t *testing.T, assert *assert.Assertions) {
 				"brandUUID": brandUUID,
 			},
 		},
+		{
+			Cypher: "MATCH (audit:ConceptAudit {conceptUUID: $conceptUUID}) DELETE audit",
+			Params: map[string]interface{}{
+				"conceptUUID": conceptUUID,
+			},
+		},
 	}
 
 	err := driver.Write(qs...)
@@ -588,6 +1124,118 @@ func deleteNode(driver *cmneo4j.Driver, uuid string) error {
 	return driver.Write(query)
 }
 
+func TestReadManyReadsEveryContent(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+
+	_, _, err = annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(conceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	defer cleanUp(t, contentUUID, v2AnnotationLifecycle, []string{conceptUUID, secondConceptUUID})
+	bookmark, _, err := annotationsService.Write(secondContentUUID, v2AnnotationLifecycle, v2PlatformVersion, nil, convertAnnotations(t, exampleConcepts(secondConceptUUID)), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	defer cleanUp(t, secondContentUUID, v2AnnotationLifecycle, []string{conceptUUID, secondConceptUUID})
+
+	anns, err := annotationsService.ReadMany([]string{contentUUID, secondContentUUID, "missing"}, bookmark, v2AnnotationLifecycle)
+	assert.NoError(err, "Failed to read annotations")
+	assert.Len(anns, 2, "Content without annotations should be left out")
+	assert.Equal(getURI(conceptUUID), anns[contentUUID].Annotations[0].ID)
+	assert.Equal(getURI(secondConceptUUID), anns[secondContentUUID].Annotations[0].ID)
+
+	anns, err = annotationsService.ReadMany([]string{contentUUID}, bookmark, PACAnnotationLifecycle)
+	assert.NoError(err, "Failed to read annotations")
+	assert.Empty(anns, "Annotations of other lifecycles should be left out")
+}
+
+func TestSummaryCountsAnnotationsAndRecordsLastWrite(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+
+	anns := append(exampleConcepts(conceptUUID), exampleConcepts(secondConceptUUID)...)
+	anns[1].Predicate = "about"
+	publication := "8e6c705e-1132-42a2-8db0-c295e29e8658"
+	before := time.Now().Add(-time.Minute)
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, []interface{}{publication}, convertAnnotations(t, anns), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	defer cleanUp(t, contentUUID, v2AnnotationLifecycle, []string{conceptUUID, secondConceptUUID})
+
+	summaries, found, err := annotationsService.Summary(contentUUID, bookmark, []string{v2AnnotationLifecycle, PACAnnotationLifecycle})
+	assert.NoError(err, "Failed to summarise annotations")
+	assert.True(found, "Summary not found")
+	assert.Len(summaries, 1, "Lifecycles without annotations should be left out")
+
+	summary := summaries[v2AnnotationLifecycle]
+	assert.Equal(2, summary.Count)
+	assert.Equal(map[string]int{"mentions": 1, "about": 1}, summary.Predicates)
+	assert.Equal(v2PlatformVersion, summary.PlatformVersion)
+	assert.Equal([]string{publication}, summary.Publication)
+	lastWritten, err := time.Parse(time.RFC3339Nano, summary.LastWritten)
+	assert.NoError(err, "Wrong lastWritten %q", summary.LastWritten)
+	assert.True(lastWritten.After(before), "lastWritten %s should be the time of the write", lastWritten)
+}
+
+func TestReadFiltersAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	driver := getNeo4jDriver(t)
+	annotationsService, err := NewCypherAnnotationsService(driver, apiHost)
+	assert.NoError(err, "creating cypher annotations service failed")
+
+	anns := append(exampleConcepts(conceptUUID), exampleConcepts(secondConceptUUID)...)
+	anns[1].Predicate = "about"
+	anns[1].RelevanceScore = 0.4
+	anns[1].ConfidenceScore = 0.5
+	publication := "8e6c705e-1132-42a2-8db0-c295e29e8658"
+	bookmark, _, err := annotationsService.Write(contentUUID, v2AnnotationLifecycle, v2PlatformVersion, []interface{}{publication}, convertAnnotations(t, anns), time.Time{}, nil)
+	assert.NoError(err, "Failed to write annotations")
+	defer cleanUp(t, contentUUID, v2AnnotationLifecycle, []string{conceptUUID, secondConceptUUID})
+
+	high, low := 0.7, 0.3
+	tests := map[string]struct {
+		filter   ReadFilter
+		expected []string
+	}{
+		"predicate name":    {ReadFilter{Predicate: "about"}, []string{secondConceptUUID}},
+		"predicate URI":     {ReadFilter{Predicate: "http://www.ft.com/ontology/annotation/mentions"}, []string{conceptUUID}},
+		"publication":       {ReadFilter{Publication: publication}, []string{conceptUUID, secondConceptUUID}},
+		"min confidence":    {ReadFilter{MinConfidence: &high}, []string{conceptUUID}},
+		"min relevance":     {ReadFilter{MinRelevance: &low}, []string{conceptUUID, secondConceptUUID}},
+		"combined":          {ReadFilter{Predicate: "about", MinRelevance: &high}, nil},
+		"other publication": {ReadFilter{Publication: "88fdde6c-2aa4-4f78-af02-9f680097cfd6"}, nil},
+	}
+	for name, test := range tests {
+		result, _, found, err := annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle, test.filter)
+		assert.NoError(err, "Unexpected error for %s", name)
+		assert.True(found, "The annotations of the lifecycle should be found for %s, even when none matches", name)
+
+		var ids []string
+		for _, ann := range *result.(*[]model.Annotation) {
+			ids = append(ids, ann.ID)
+			assert.Equal([]string{publication}, ann.Publication, "Wrong publication for %s", name)
+			assert.NotEmpty(ann.Types, "Expected the types of the concept for %s", name)
+		}
+		var expected []string
+		for _, uuid := range test.expected {
+			expected = append(expected, getURI(uuid))
+		}
+		assert.ElementsMatch(expected, ids, "Wrong annotations for %s", name)
+	}
+
+	result, _, found, err := annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle, ReadFilter{Predicate: "about", MinRelevance: &high})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(&[]model.Annotation{}, result, "A filter matching nothing should read an empty list")
+
+	_, _, found, err = annotationsService.Read(contentUUID, bookmark, PACAnnotationLifecycle, ReadFilter{Predicate: "about"})
+	assert.NoError(err)
+	assert.False(found, "A lifecycle without annotations should not be found, filtered or not")
+
+	_, _, _, err = annotationsService.Read(contentUUID, bookmark, v2AnnotationLifecycle, ReadFilter{Predicate: "likes"})
+	assert.ErrorIs(err, ErrUnknownPredicate)
+}
+
 func exampleConcepts(uuid string) model.Annotations {
 	return model.Annotations{
 		model.Annotation{
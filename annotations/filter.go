@@ -0,0 +1,91 @@
+package annotations
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Financial-Times/cm-annotations-ontology/model"
+	cmneo4j "github.com/Financial-Times/cm-neo4j-driver"
+)
+
+// ReadFilter narrows down the annotations returned by Read. The zero value matches every annotation.
+type ReadFilter struct {
+	// Predicate is either a predicate name, such as mentions, or its full URI
+	Predicate   string
+	Publication string
+	// MinConfidence and MinRelevance leave out the annotations without a score
+	MinConfidence *float64
+	MinRelevance  *float64
+}
+
+// IsEmpty tells if the filter matches every annotation
+func (f ReadFilter) IsEmpty() bool {
+	return f == ReadFilter{}
+}
+
+// filteredAnnotations holds the annotations matching a filter, read as a single row so that a filter matching
+// nothing still returns a row
+type filteredAnnotations struct {
+	Annotations []model.Annotation `json:"annotations"`
+}
+
+// query reads the annotations of a lifecycle for a piece of content matching the filter, in the shape of those read
+// by neo4j.GetReadQuery: the UUID and the relationship type as the id and predicate, along with the prefLabel and
+// labels of the concept
+func (f ReadFilter) query(contentUUID string, annotationLifecycle string, result *[]filteredAnnotations) (*cmneo4j.Query, error) {
+	conditions, params, err := f.conditions()
+	if err != nil {
+		return nil, err
+	}
+	params["contentUUID"] = contentUUID
+	params["lifecycle"] = annotationLifecycle
+
+	return &cmneo4j.Query{
+		Cypher: `OPTIONAL MATCH (content:Thing{uuid:$contentUUID})-[rel]->(concept:Thing)
+			WHERE ` + strings.Join(append([]string{"rel.lifecycle = $lifecycle"}, conditions...), " AND ") + `
+			WITH concept, rel
+			ORDER BY concept.uuid, type(rel)
+			RETURN collect(CASE WHEN rel IS NOT NULL THEN {
+				id: concept.uuid,
+				prefLabel: concept.prefLabel,
+				types: labels(concept),
+				predicate: type(rel),
+				relevanceScore: rel.relevanceScore,
+				confidenceScore: rel.confidenceScore,
+				annotatedBy: rel.annotatedBy,
+				annotatedDate: rel.annotatedDate,
+				annotatedDateEpoch: rel.annotatedDateEpoch,
+				publication: rel.publication
+			} END) AS annotations`,
+		Params: params,
+		Result: result,
+	}, nil
+}
+
+// conditions returns the Cypher conditions on an annotation relationship `rel` applying the filter, along with their parameters
+func (f ReadFilter) conditions() ([]string, map[string]interface{}, error) {
+	var conditions []string
+	params := map[string]interface{}{}
+	if f.Predicate != "" {
+		relation, ok := model.Relations[path.Base(f.Predicate)]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %q", ErrUnknownPredicate, f.Predicate)
+		}
+		conditions = append(conditions, "type(rel) = $relation")
+		params["relation"] = relation
+	}
+	if f.Publication != "" {
+		conditions = append(conditions, "$publication IN coalesce(rel.publication, [])")
+		params["publication"] = f.Publication
+	}
+	if f.MinConfidence != nil {
+		conditions = append(conditions, "rel.confidenceScore >= $minConfidence")
+		params["minConfidence"] = *f.MinConfidence
+	}
+	if f.MinRelevance != nil {
+		conditions = append(conditions, "rel.relevanceScore >= $minRelevance")
+		params["minRelevance"] = *f.MinRelevance
+	}
+	return conditions, params, nil
+}
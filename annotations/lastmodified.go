@@ -0,0 +1,33 @@
+package annotations
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// lastModifiedPrefix prefixes the property of the content node holding when the annotations of a lifecycle
+// were last modified according to the last write carrying a time, in milliseconds since the epoch. It lives on the content node rather than on the annotations,
+// so that it is kept when a lifecycle is written without any annotation.
+const lastModifiedPrefix = "lastModified_"
+
+// validLifecycle matches the lifecycles which can be part of a property name
+var validLifecycle = regexp.MustCompile(`^[\w\-]+$`)
+
+func lastModifiedProperty(annotationLifecycle string) (string, error) {
+	if !validLifecycle.MatchString(annotationLifecycle) {
+		return "", fmt.Errorf("invalid annotation lifecycle %q", annotationLifecycle)
+	}
+	return "`" + lastModifiedPrefix + annotationLifecycle + "`", nil
+}
+
+// setLastModified sets the last modified time of the `content` node held by a property to the given time,
+// unless it already holds a later one, so the recorded time never moves backwards
+func setLastModified(property string, modified string) string {
+	return fmt.Sprintf("SET content.%[1]s = CASE WHEN content.%[1]s > %[2]s THEN content.%[1]s ELSE %[2]s END", property, modified)
+}
+
+// stale tells if a write is older than the last write of its lifecycle. Writes without a time are never stale.
+func stale(lastModified time.Time, stored *int64) bool {
+	return !lastModified.IsZero() && stored != nil && lastModified.UnixMilli() < *stored
+}
@@ -0,0 +1,275 @@
+package annotations
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/cm-annotations-ontology/model"
+	cmneo4j "github.com/Financial-Times/cm-neo4j-driver"
+)
+
+// storedAnnotationColumns returns the properties of an annotation relationship `rel` to a `concept` node
+// in the shape expected by storedAnnotation
+const storedAnnotationColumns = `rel.lifecycle AS lifecycle,
+	concept.uuid AS conceptId,
+	type(rel) AS relation,
+	rel.platformVersion AS platformVersion,
+	rel.relevanceScore AS relevanceScore,
+	rel.confidenceScore AS confidenceScore,
+	rel.annotatedBy AS annotatedBy,
+	rel.annotatedDate AS annotatedDate,
+	rel.annotatedDateEpoch AS annotatedDateEpoch,
+	rel.publication AS publication,
+	rel.payload AS payload`
+
+// Groupings supported when counting annotations
+const (
+	GroupByPredicate       = "predicate"
+	GroupByPublication     = "publication"
+	GroupByPlatformVersion = "platformVersion"
+)
+
+// NoPublication is the publication annotations written without one are counted under
+const NoPublication = "none"
+
+// conceptDetails are the fields of an annotation payload describing its concept rather than the annotation
+var conceptDetails = []string{"prefLabel", "types", "type", "apiUrl", "isFTAuthor"}
+
+// predicateURIs maps the predicate names used by the ontology to the full URIs sent by PAC-like producers
+var predicateURIs = map[string]string{
+	"mentions":                "http://www.ft.com/ontology/annotation/mentions",
+	"isClassifiedBy":          "http://www.ft.com/ontology/classification/isClassifiedBy",
+	"implicitlyClassifiedBy":  "http://www.ft.com/ontology/implicitlyClassifiedBy",
+	"about":                   "http://www.ft.com/ontology/annotation/about",
+	"isPrimarilyClassifiedBy": "http://www.ft.com/ontology/isPrimarilyClassifiedBy",
+	"majorMentions":           "http://www.ft.com/ontology/majorMentions",
+	"hasAuthor":               "http://www.ft.com/ontology/annotation/hasAuthor",
+	"hasContributor":          "http://www.ft.com/ontology/hasContributor",
+	"hasDisplayTag":           "http://www.ft.com/ontology/hasDisplayTag",
+	"hasBrand":                "http://www.ft.com/ontology/hasBrand",
+	"isSponsoredBy":           "http://www.ft.com/ontology/annotation/isSponsoredBy",
+	"hasSource":               "http://www.ft.com/ontology/annotation/hasSource",
+	"hasReference":            "http://www.ft.com/ontology/annotation/hasReference",
+}
+
+// predicates is the reverse of model.Relations, from relationship type to predicate name
+var predicates = func() map[string]string {
+	result := map[string]string{}
+	for predicate, relation := range model.Relations {
+		if existing, ok := result[relation]; ok && existing < predicate {
+			continue
+		}
+		result[relation] = predicate
+	}
+	return result
+}()
+
+// AnnotationRef identifies a single annotation of a piece of content by the annotating concept and the predicate.
+// Both the ID and the predicate may be given as a full URI or as the bare value.
+type AnnotationRef struct {
+	ID        string `json:"id"`
+	Predicate string `json:"predicate"`
+}
+
+func (r AnnotationRef) conceptUUID() string {
+	return path.Base(r.ID)
+}
+
+func (r AnnotationRef) relation() (string, bool) {
+	relation, ok := model.Relations[path.Base(r.Predicate)]
+	return relation, ok
+}
+
+// ContentWrite holds the annotations a lifecycle writes for a single piece of content as part of a batch
+type ContentWrite struct {
+	ContentUUID     string
+	Lifecycle       string
+	PlatformVersion string
+	Publication     []interface{}
+	Annotations     interface{}
+	// LastModified is when the annotations were modified, the zero time skipping the check for stale writes
+	LastModified time.Time
+	// IfMatch lists the versions the stored annotations must have for the write to happen, "*" matching any version
+	// of existing annotations. The write is not conditional on the version when it is nil.
+	IfMatch []string
+}
+
+// storedAnnotation is an annotation relationship as it has been persisted by this writer.
+// ContentID is only returned by queries spanning many pieces of content.
+type storedAnnotation struct {
+	ContentID          string   `json:"contentId"`
+	Lifecycle          string   `json:"lifecycle"`
+	ConceptID          string   `json:"conceptId"`
+	Relation           string   `json:"relation"`
+	PlatformVersion    string   `json:"platformVersion"`
+	RelevanceScore     *float64 `json:"relevanceScore"`
+	ConfidenceScore    *float64 `json:"confidenceScore"`
+	AnnotatedBy        string   `json:"annotatedBy"`
+	AnnotatedDate      string   `json:"annotatedDate"`
+	AnnotatedDateEpoch *int64   `json:"annotatedDateEpoch"`
+	Publication        []string `json:"publication"`
+	// Payload is the annotation as it has been written, in JSON, empty for annotations written before it was persisted
+	Payload string `json:"payload"`
+}
+
+// PayloadAnnotation is an annotation in the same format as the body of a PUT request
+type PayloadAnnotation struct {
+	ID                 string   `json:"id"`
+	Predicate          string   `json:"predicate"`
+	RelevanceScore     *float64 `json:"relevanceScore,omitempty"`
+	ConfidenceScore    *float64 `json:"confidenceScore,omitempty"`
+	AnnotatedBy        string   `json:"annotatedBy,omitempty"`
+	AnnotatedDate      string   `json:"annotatedDate,omitempty"`
+	AnnotatedDateEpoch *int64   `json:"annotatedDateEpoch,omitempty"`
+	// original is the payload the annotation has been written with, which is marshalled instead of the fields when set
+	original json.RawMessage
+}
+
+// MarshalJSON returns the payload the annotation has been written with when it is known, so that annotations are
+// read and forwarded with all the fields they have been written with, such as the prefLabel and types of their concept
+func (a PayloadAnnotation) MarshalJSON() ([]byte, error) {
+	if len(a.original) > 0 {
+		return a.original, nil
+	}
+	type fields PayloadAnnotation
+	return json.Marshal(fields(a))
+}
+
+// LifecycleAnnotations holds the annotations a single lifecycle has written for a piece of content
+type LifecycleAnnotations struct {
+	Publication []string            `json:"publication,omitempty"`
+	Annotations []PayloadAnnotation `json:"annotations"`
+}
+
+// LifecycleSummary gives an overview of the annotations a single lifecycle has written for a piece of content
+type LifecycleSummary struct {
+	Count           int            `json:"count"`
+	Predicates      map[string]int `json:"predicates"`
+	PlatformVersion string         `json:"platformVersion,omitempty"`
+	Publication     []string       `json:"publication,omitempty"`
+	// LastWritten is empty for annotations written before write times were recorded
+	LastWritten string `json:"lastWritten,omitempty"`
+}
+
+// ContentAnnotations holds the annotations a single lifecycle has written for the content with the given UUID
+type ContentAnnotations struct {
+	UUID string `json:"uuid"`
+	LifecycleAnnotations
+}
+
+// payload converts a stored annotation back to the format it was written in. The persisted payload is used when there
+// is one. Otherwise, for annotations written before payloads were persisted, the predicate follows the split between
+// scored producers (next-video, v2 suggestions) sending bare predicate names and PAC-like producers sending full
+// ontology URIs, in order for the result to pass the same schema validation as the original payload.
+func (a storedAnnotation) payload(publicAPIURL string) PayloadAnnotation {
+	predicate := predicates[a.Relation]
+	if uri, ok := predicateURIs[predicate]; ok && a.RelevanceScore == nil && a.ConfidenceScore == nil {
+		predicate = uri
+	}
+
+	result := PayloadAnnotation{
+		ID:                 thingURL(a.ConceptID, publicAPIURL),
+		Predicate:          predicate,
+		RelevanceScore:     a.RelevanceScore,
+		ConfidenceScore:    a.ConfidenceScore,
+		AnnotatedBy:        a.AnnotatedBy,
+		AnnotatedDate:      a.AnnotatedDate,
+		AnnotatedDateEpoch: a.AnnotatedDateEpoch,
+	}
+	if a.Payload == "" {
+		return result
+	}
+
+	var original map[string]interface{}
+	if err := json.Unmarshal([]byte(a.Payload), &original); err != nil {
+		return result
+	}
+	if id, _ := original["id"].(string); path.Base(id) != a.ConceptID {
+		// the annotation has been remapped to another concept, whose details are not known
+		original["id"] = strings.TrimSuffix(id, path.Base(id)) + a.ConceptID
+		for _, field := range conceptDetails {
+			delete(original, field)
+		}
+	}
+	result.Predicate, _ = original["predicate"].(string)
+	result.original, _ = json.Marshal(original)
+	return result
+}
+
+// payloadsQuery persists the payload of every annotation written for a piece of content on its relationship,
+// so that it can be read and forwarded as it has been written. It has to run after the queries writing the
+// annotations, in the same transaction.
+func payloadsQuery(contentUUID string, annotationLifecycle string, anns []map[string]interface{}) (*cmneo4j.Query, error) {
+	payloads := make([]map[string]interface{}, 0, len(anns))
+	for _, ann := range anns {
+		id, _ := ann["id"].(string)
+		predicate, _ := ann["predicate"].(string)
+		relation, ok := AnnotationRef{ID: id, Predicate: predicate}.relation()
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownPredicate, predicate)
+		}
+		payload, err := json.Marshal(ann)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, map[string]interface{}{
+			"conceptUUID": path.Base(id),
+			"relation":    relation,
+			"payload":     string(payload),
+		})
+	}
+	return &cmneo4j.Query{
+		Cypher: `UNWIND $payloads AS payload
+			MATCH (content:Thing{uuid:$contentUUID})-[rel]->(:Thing{uuid:payload.conceptUUID})
+			WHERE rel.lifecycle = $lifecycle AND type(rel) = payload.relation
+			SET rel.payload = payload.payload`,
+		Params: map[string]interface{}{
+			"contentUUID": contentUUID,
+			"lifecycle":   annotationLifecycle,
+			"payloads":    payloads,
+		},
+	}, nil
+}
+
+func groupByLifecycle(stored []storedAnnotation, publicAPIURL string) map[string]LifecycleAnnotations {
+	sort.SliceStable(stored, func(i, j int) bool {
+		if stored[i].Lifecycle != stored[j].Lifecycle {
+			return stored[i].Lifecycle < stored[j].Lifecycle
+		}
+		if stored[i].ConceptID != stored[j].ConceptID {
+			return stored[i].ConceptID < stored[j].ConceptID
+		}
+		return stored[i].Relation < stored[j].Relation
+	})
+
+	result := map[string]LifecycleAnnotations{}
+	for _, ann := range stored {
+		group, ok := result[ann.Lifecycle]
+		if !ok {
+			group.Publication = ann.Publication
+		}
+		group.Annotations = append(group.Annotations, ann.payload(publicAPIURL))
+		result[ann.Lifecycle] = group
+	}
+	return result
+}
+
+// groupByContent groups annotations already ordered by content UUID, keeping that order
+func groupByContent(stored []storedAnnotation, publicAPIURL string) []ContentAnnotations {
+	var result []ContentAnnotations
+	for _, ann := range stored {
+		if len(result) == 0 || result[len(result)-1].UUID != ann.ContentID {
+			result = append(result, ContentAnnotations{
+				UUID:                 ann.ContentID,
+				LifecycleAnnotations: LifecycleAnnotations{Publication: ann.Publication},
+			})
+		}
+		last := &result[len(result)-1]
+		last.Annotations = append(last.Annotations, ann.payload(publicAPIURL))
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package annotations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// IsTransient tells if an error returned by the service may go away when the operation is tried again,
+// as opposed to a permanent error such as an invalid annotation or a constraint violation.
+// Transient errors are the transient errors reported by Neo4j, writes reaching a follower during a leader switch,
+// and the connectivity errors of the driver and of the network.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) {
+		return neo4jErr.IsRetriableTransient() || neo4jErr.IsRetriableCluster()
+	}
+
+	var connectivityErr *neo4j.ConnectivityError
+	var netErr net.Error
+	return errors.Is(err, ErrConcurrentWrite) ||
+		errors.As(err, &connectivityErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+)
+
+// writeBatcher collects the writes of the messages being processed, so that they are written to Neo4j in a single
+// transaction. A batch is written once it holds size writes, or wait after its first write was added.
+//
+// Adding a write does not wait for it to be written: its outcome is handed to a callback once its batch has been
+// written. The batches are written one after the other by a single goroutine, in the order their writes were added,
+// so the writes for a piece of content are never reordered, while the next batch fills up as one is written.
+// Adding only waits while a whole batch is already waiting to be written.
+type writeBatcher struct {
+	size  int
+	wait  time.Duration
+	write func(batch []*batchedWrite)
+
+	// incoming holds the writes not picked up by the writing goroutine yet, in the order they were added
+	incoming chan *batchedWrite
+}
+
+// batchedWrite is the write of a single message, along with its outcome once its batch has been written
+type batchedWrite struct {
+	tid      string
+	write    annotations.ContentWrite
+	bookmark string
+	changed  bool
+	err      error
+	// written is called with the outcome of the write once its batch has been written
+	written func(bookmark string, changed bool, err error)
+}
+
+func newWriteBatcher(size int, wait time.Duration, write func(batch []*batchedWrite)) *writeBatcher {
+	b := &writeBatcher{size: size, wait: wait, write: write, incoming: make(chan *batchedWrite, size)}
+	go b.writeBatches()
+	return b
+}
+
+// add puts a write in the current batch, calling written once the batch has been written
+func (b *writeBatcher) add(tid string, write annotations.ContentWrite, written func(bookmark string, changed bool, err error)) {
+	b.incoming <- &batchedWrite{tid: tid, write: write, written: written}
+}
+
+func (b *writeBatcher) writeBatches() {
+	var batch []*batchedWrite
+	timer := time.NewTimer(b.wait)
+	timer.Stop()
+	for {
+		select {
+		case w := <-b.incoming:
+			if len(batch) == 0 {
+				timer.Reset(b.wait)
+			}
+			batch = append(batch, w)
+			if len(batch) >= b.size {
+				batch = b.flush(batch, timer)
+			}
+		case <-timer.C:
+			batch = b.flush(batch, timer)
+		}
+	}
+}
+
+// flush writes a batch and hands the writes their outcome, returning the next, empty, batch
+func (b *writeBatcher) flush(batch []*batchedWrite, timer *time.Timer) []*batchedWrite {
+	if len(batch) == 0 {
+		return batch
+	}
+	if !timer.Stop() {
+		// drain the channel of a timer which fired while the batch was being filled up
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	b.write(batch)
+	for _, w := range batch {
+		w.written(w.bookmark, w.changed, w.err)
+	}
+	return nil
+}
+
+// orderByContent sorts the writes of a batch by content UUID, keeping the order of the writes for the same content,
+// so that concurrent transactions lock the content nodes in the same order
+func orderByContent(batch []*batchedWrite) []*batchedWrite {
+	ordered := append([]*batchedWrite{}, batch...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].write.ContentUUID < ordered[j].write.ContentUUID
+	})
+	return ordered
+}
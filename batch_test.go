@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordBatches returns a batch writer recording the content of the batches it writes
+func recordBatches(mu *sync.Mutex, batches *[][]string) func(batch []*batchedWrite) {
+	return func(batch []*batchedWrite) {
+		var contents []string
+		for _, w := range batch {
+			contents = append(contents, w.write.ContentUUID)
+			w.bookmark, w.changed = "bookmark", true
+		}
+		mu.Lock()
+		*batches = append(*batches, contents)
+		mu.Unlock()
+	}
+}
+
+func writtenBatches(mu *sync.Mutex, batches *[][]string) [][]string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([][]string{}, *batches...)
+}
+
+func TestWriteBatcherWritesFullBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	b := newWriteBatcher(3, time.Minute, recordBatches(&mu, &batches))
+
+	var wg sync.WaitGroup
+	for _, uuid := range []string{"1", "2", "3"} {
+		wg.Add(1)
+		b.add("tid_"+uuid, annotations.ContentWrite{ContentUUID: uuid}, func(bookmark string, changed bool, err error) {
+			defer wg.Done()
+			assert.NoError(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, "bookmark", bookmark)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, [][]string{{"1", "2", "3"}}, writtenBatches(&mu, &batches), "A full batch should be written without waiting")
+}
+
+func TestWriteBatcherDoesNotWaitForTheBatchToBeWritten(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	b := newWriteBatcher(10, time.Minute, recordBatches(&mu, &batches))
+
+	added := make(chan struct{})
+	go func() {
+		for _, uuid := range []string{"1", "2", "3"} {
+			b.add("tid_"+uuid, annotations.ContentWrite{ContentUUID: uuid}, func(string, bool, error) {})
+		}
+		close(added)
+	}()
+
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adding writes should not wait for their batch to be written")
+	}
+	assert.Empty(t, writtenBatches(&mu, &batches))
+}
+
+func TestWriteBatcherWritesIncompleteBatchesAfterWaiting(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	b := newWriteBatcher(10, 20*time.Millisecond, recordBatches(&mu, &batches))
+
+	written := make(chan struct{})
+	start := time.Now()
+	b.add("tid_1", annotations.ContentWrite{ContentUUID: "1"}, func(_ string, _ bool, err error) {
+		assert.NoError(t, err)
+		close(written)
+	})
+	<-written
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	written = make(chan struct{})
+	b.add("tid_2", annotations.ContentWrite{ContentUUID: "2"}, func(_ string, _ bool, err error) {
+		assert.NoError(t, err)
+		close(written)
+	})
+	<-written
+
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, [][]string{{"1"}, {"2"}}, writtenBatches(&mu, &batches), "Every batch should be written exactly once")
+}
+
+func TestOrderByContentKeepsTheOrderOfTheWritesForTheSameContent(t *testing.T) {
+	batch := []*batchedWrite{
+		{tid: "tid_1", write: annotations.ContentWrite{ContentUUID: "b"}},
+		{tid: "tid_2", write: annotations.ContentWrite{ContentUUID: "a"}},
+		{tid: "tid_3", write: annotations.ContentWrite{ContentUUID: "b"}},
+		{tid: "tid_4", write: annotations.ContentWrite{ContentUUID: "a"}},
+	}
+
+	var tids []string
+	for _, w := range orderByContent(batch) {
+		tids = append(tids, w.tid)
+	}
+
+	assert.Equal(t, []string{"tid_2", "tid_4", "tid_1", "tid_3"}, tids)
+	assert.Equal(t, "tid_1", batch[0].tid, "The batch itself should be left as it is")
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v3"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	// defaultLagTolerance is the lag tolerance of the consumer when none is configured, as for kafka-client-go
+	defaultLagTolerance = 500
+	// defaultConnectionRetryInterval is how long the consumer waits before connecting again when Kafka cannot be reached
+	defaultConnectionRetryInterval = time.Minute
+)
+
+// orderedConsumer consumes Kafka topics in a consumer group without waiting for a message to be processed before
+// reading the next message of its partition. Every message is handed over along with a function to call once it has
+// been processed, and its offset is only marked for commit once the message and every message before it in its
+// partition have been processed, so that the messages being processed when the service stops are consumed again
+// rather than lost.
+//
+// The consumer of kafka-client-go marks the offset of a message as soon as its handler returns, and offers no way to
+// mark it later, which is why this consumer uses the consumer group of sarama directly. It is used through the
+// kafkaConsumer interface like the consumer of kafka-client-go, and reads messages the same way.
+type orderedConsumer struct {
+	config       kafka.ConsumerConfig
+	topics       []string
+	lagTolerance int64
+	// maxInFlight bounds the number of messages of a partition being processed at the same time
+	maxInFlight int
+	log         *logger.UPPLogger
+
+	handler func(message kafka.FTMessage, done func())
+
+	mu     sync.RWMutex
+	group  sarama.ConsumerGroup
+	cancel context.CancelFunc
+	// partitions holds the offsets of the claimed partitions, keyed by topic and partition
+	partitions map[string]*partitionOffsets
+}
+
+func newOrderedConsumer(config kafka.ConsumerConfig, topics []string, lagTolerance int64, maxInFlight int, log *logger.UPPLogger) *orderedConsumer {
+	if config.Options == nil {
+		config.Options = kafka.DefaultConsumerOptions()
+	}
+	if lagTolerance <= 0 {
+		lagTolerance = defaultLagTolerance
+	}
+	return &orderedConsumer{
+		config:       config,
+		topics:       topics,
+		lagTolerance: lagTolerance,
+		maxInFlight:  maxInFlight,
+		log:          log,
+		partitions:   map[string]*partitionOffsets{},
+	}
+}
+
+// Start consumes the topics in the background, handing over a message only once the previous message of its partition
+// has been processed
+func (c *orderedConsumer) Start(handler func(message kafka.FTMessage)) {
+	c.StartAsync(func(message kafka.FTMessage, done func()) {
+		handler(message)
+		done()
+	})
+}
+
+// StartAsync consumes the topics, handing over every message without waiting for the previous ones to be processed.
+// done has to be called once for every message. It returns straight away: the consumer connects to Kafka in the
+// background, trying again until it succeeds, and reports that it is not connected in the meantime.
+func (c *orderedConsumer) StartAsync(handler func(message kafka.FTMessage, done func())) {
+	c.handler = handler
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	go func() {
+		group, ok := c.connect(ctx)
+		if !ok {
+			return
+		}
+		go func() {
+			for err := range group.Errors() {
+				c.log.WithError(err).Error("Error consuming message")
+			}
+		}()
+		c.log.Info("Starting consumer...")
+		for ctx.Err() == nil {
+			if err := group.Consume(ctx, c.topics, c); err != nil {
+				c.log.WithError(err).Error("Error occurred during consumer group lifecycle")
+			}
+		}
+	}()
+}
+
+// connect creates the consumer group, trying again until it succeeds or the consumer is closed
+func (c *orderedConsumer) connect(ctx context.Context) (sarama.ConsumerGroup, bool) {
+	interval := c.config.ConnectionRetryInterval
+	if interval <= 0 {
+		interval = defaultConnectionRetryInterval
+	}
+	log := c.log.WithField("brokers", c.config.BrokersConnectionString).
+		WithField("topics", c.topics).
+		WithField("consumer_group", c.config.ConsumerGroup)
+	for {
+		group, err := sarama.NewConsumerGroup(c.brokers(), c.config.ConsumerGroup, c.config.Options)
+		if err == nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if ctx.Err() != nil {
+				// closed while connecting
+				_ = group.Close()
+				return nil, false
+			}
+			c.group = group
+			log.Info("Established Kafka consumer group connection")
+			return group, true
+		}
+		log.WithError(err).Warn("Error creating Kafka consumer group")
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (c *orderedConsumer) brokers() []string {
+	return strings.Split(c.config.BrokersConnectionString, ",")
+}
+
+func (c *orderedConsumer) connected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.group != nil
+}
+
+// Close stops consuming, once the messages being processed have been processed and their offsets committed,
+// or stops connecting when the consumer is not connected yet
+func (c *orderedConsumer) Close() error {
+	c.mu.RLock()
+	group, cancel := c.group, c.cancel
+	c.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+	if group == nil {
+		return nil
+	}
+	return group.Close()
+}
+
+// ConnectivityCheck checks whether a connection to Kafka can be established
+func (c *orderedConsumer) ConnectivityCheck() error {
+	if !c.connected() {
+		return kafka.ErrConsumerNotConnected
+	}
+	client, err := sarama.NewClient(c.brokers(), c.config.Options)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+// MonitorCheck reports the claimed partitions whose messages are not processed as fast as they are produced,
+// which lag behind their latest message by more than the lag tolerance
+func (c *orderedConsumer) MonitorCheck() error {
+	if !c.connected() {
+		return kafka.ErrMonitorNotConnected
+	}
+
+	c.mu.RLock()
+	var lagging []string
+	for key, offsets := range c.partitions {
+		if lag := offsets.lag(); lag > c.lagTolerance {
+			lagging = append(lagging, fmt.Sprintf("%s by %d messages", key, lag))
+		}
+	}
+	c.mu.RUnlock()
+	if len(lagging) > 0 {
+		sort.Strings(lagging)
+		return fmt.Errorf("consumer is lagging behind on %s", strings.Join(lagging, ", "))
+	}
+	return nil
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (c *orderedConsumer) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
+func (c *orderedConsumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim hands over the messages of a claimed partition, at most maxInFlight at a time, and waits for the ones
+// being processed once the partition is released, so that their offsets are marked before the session ends
+func (c *orderedConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	key := fmt.Sprintf("%s/%d", claim.Topic(), claim.Partition())
+	offsets := newPartitionOffsets(session, claim)
+	c.mu.Lock()
+	c.partitions[key] = offsets
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.partitions, key)
+		c.mu.Unlock()
+	}()
+
+	slots := make(chan struct{}, c.maxInFlight)
+	var processing sync.WaitGroup
+	for message := range claim.Messages() {
+		slots <- struct{}{}
+		processing.Add(1)
+		processed := offsets.add(message)
+		c.handler(parseFTMessage(message.Value), func() {
+			processed()
+			<-slots
+			processing.Done()
+		})
+	}
+	processing.Wait()
+	return nil
+}
+
+// partitionOffsets marks the offsets of the messages of a claimed partition for commit, in order, once processed
+type partitionOffsets struct {
+	session sarama.ConsumerGroupSession
+	claim   sarama.ConsumerGroupClaim
+
+	mu sync.Mutex
+	// pending holds the messages handed over whose offsets have not been marked yet, in the order of their offsets
+	pending []*pendingMessage
+	// next is the offset of the first message not processed yet, or -1 when it is not known
+	next int64
+}
+
+type pendingMessage struct {
+	message   *sarama.ConsumerMessage
+	processed bool
+}
+
+func newPartitionOffsets(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) *partitionOffsets {
+	next := claim.InitialOffset()
+	if next < 0 {
+		next = -1
+	}
+	return &partitionOffsets{session: session, claim: claim, next: next}
+}
+
+// add records a message handed over, returning the function to call once it has been processed
+func (p *partitionOffsets) add(message *sarama.ConsumerMessage) func() {
+	m := &pendingMessage{message: message}
+	p.mu.Lock()
+	if p.next < 0 {
+		p.next = message.Offset
+	}
+	p.pending = append(p.pending, m)
+	p.mu.Unlock()
+	return func() {
+		p.processed(m)
+	}
+}
+
+// processed marks the offsets of the processed messages which no earlier message is still being processed for
+func (p *partitionOffsets) processed(m *pendingMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m.processed = true
+	for len(p.pending) > 0 && p.pending[0].processed {
+		p.session.MarkMessage(p.pending[0].message, "")
+		p.next = p.pending[0].message.Offset + 1
+		p.pending = p.pending[1:]
+	}
+}
+
+// lag counts the messages of the partition which have not been processed, whether they have been consumed or not
+func (p *partitionOffsets) lag() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next < 0 {
+		return 0
+	}
+	return p.claim.HighWaterMarkOffset() - p.next
+}
+
+// The patterns kafka-client-go reads the headers of a message with, the characters outside of them ending a header
+var (
+	ftHeader      = regexp.MustCompile(`[\w-]*:[\w\-:/.+;= ]*`)
+	ftHeaderName  = regexp.MustCompile(`[\w-]*:`)
+	ftHeaderValue = regexp.MustCompile(`:[\w-:/.+;= ]*`)
+)
+
+// parseFTMessage reads a message in the format of the FT messages consumed from Kafka, the way the consumer of
+// kafka-client-go does: a first line giving the version of the format, a header per line, then an empty line and
+// the body. kafka-client-go does not export its parser; consumer_test.go checks that both read messages alike.
+func parseFTMessage(raw []byte) kafka.FTMessage {
+	text := string(raw)
+	end := strings.Index(text, "\r\n\r\n")
+	if end == -1 {
+		end = strings.Index(text, "\n\n")
+	}
+	if end == -1 {
+		end = len(text)
+	}
+
+	headers := map[string]string{}
+	for _, header := range ftHeader.FindAllString(text[:end], -1) {
+		name := ftHeaderName.FindString(header)
+		value := ftHeaderValue.FindString(header)
+		headers[name[:len(name)-1]] = strings.TrimSpace(value[1:])
+	}
+	return kafka.NewFTMessage(headers, strings.TrimSpace(text[end:]))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	_ "unsafe" // for linking to the parser of kafka-client-go
+
+	"github.com/Financial-Times/kafka-client-go/v3"
+
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/forwarder"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSession records the offsets marked for commit
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) MarkMessage(message *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, message.Offset)
+}
+
+func (s *fakeSession) markedOffsets() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64{}, s.marked...)
+}
+
+// fakeClaim is a claimed partition holding the given messages
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages      chan *sarama.ConsumerMessage
+	initialOffset int64
+	highWaterMark int64
+}
+
+// newFakeClaim returns a claimed partition holding the given messages from offset 0, and no other message
+func newFakeClaim(messages ...kafka.FTMessage) *fakeClaim {
+	return newFakeClaimAt(0, int64(len(messages)), messages...)
+}
+
+// newFakeClaimAt returns a claimed partition consumed from the given offset, whose messages from that offset are the
+// given ones, and whose next message will be at highWaterMark
+func newFakeClaimAt(offset int64, highWaterMark int64, messages ...kafka.FTMessage) *fakeClaim {
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(messages)), initialOffset: offset, highWaterMark: highWaterMark}
+	for i, message := range messages {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "PostConceptAnnotations", Offset: offset + int64(i), Value: []byte(message.Build())}
+	}
+	close(claim.messages)
+	return claim
+}
+
+func (c *fakeClaim) Topic() string                            { return "PostConceptAnnotations" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return c.initialOffset }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return c.highWaterMark }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+type fakeConsumerGroup struct {
+	sarama.ConsumerGroup
+}
+
+// contentMessage returns a message for a piece of content, told apart from the others by its request id
+func contentMessage(contentUUID string, n int) kafka.FTMessage {
+	return kafka.NewFTMessage(map[string]string{"X-Request-Id": fmt.Sprintf("tid_%d", n)}, fmt.Sprintf(`{"uuid":%q}`, contentUUID))
+}
+
+func newTestOrderedConsumer(maxInFlight int, handler func(message kafka.FTMessage, done func())) *orderedConsumer {
+	c := newOrderedConsumer(kafka.ConsumerConfig{}, []string{"PostConceptAnnotations"}, 1, maxInFlight, logger.NewUPPInfoLogger("annotations-rw"))
+	c.handler = handler
+	return c
+}
+
+func TestOrderedConsumerMarksOffsetsInOrderOnceProcessed(t *testing.T) {
+	handed := make(chan func(), 3)
+	c := newTestOrderedConsumer(10, func(message kafka.FTMessage, done func()) {
+		handed <- done
+	})
+	session := &fakeSession{}
+	claim := newFakeClaim(contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 1), contentMessage("0f3b5b2c-9e4a-4a3c-8f51-0b4d2c1e6a77", 2), contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 3))
+
+	released := make(chan struct{})
+	go func() {
+		_ = c.ConsumeClaim(session, claim)
+		close(released)
+	}()
+	first, second, third := <-handed, <-handed, <-handed
+
+	second()
+	assert.Empty(t, session.markedOffsets(), "no offset can be marked before the messages before it are processed")
+	first()
+	assert.Equal(t, []int64{0, 1}, session.markedOffsets())
+	select {
+	case <-released:
+		t.Fatal("the partition has been released before its messages were processed")
+	default:
+	}
+	third()
+
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the partition has not been released once its messages were processed")
+	}
+	assert.Equal(t, []int64{0, 1, 2}, session.markedOffsets())
+}
+
+func TestOrderedConsumerLimitsMessagesInFlight(t *testing.T) {
+	handed := make(chan func(), 3)
+	c := newTestOrderedConsumer(2, func(message kafka.FTMessage, done func()) {
+		handed <- done
+	})
+	claim := newFakeClaim(contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 1), contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 2), contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 3))
+	go func() {
+		_ = c.ConsumeClaim(&fakeSession{}, claim)
+	}()
+
+	first := <-handed
+	<-handed
+	select {
+	case <-handed:
+		t.Fatal("more messages than maxInFlight have been handed over")
+	case <-time.After(50 * time.Millisecond):
+	}
+	first()
+	select {
+	case <-handed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the next message has not been handed over once a message was processed")
+	}
+}
+
+func TestOrderedConsumerMonitorCheckReportsLag(t *testing.T) {
+	c := newTestOrderedConsumer(10, func(message kafka.FTMessage, done func()) {})
+	assert.True(t, errors.Is(c.MonitorCheck(), kafka.ErrMonitorNotConnected), "ErrMonitorNotConnected is expected before connecting")
+
+	c.group = fakeConsumerGroup{}
+	claim := newFakeClaim(contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 1), contentMessage("a7732a22-3884-4bfe-9761-fef161e41d69", 2))
+	c.partitions["PostConceptAnnotations/0"] = newPartitionOffsets(&fakeSession{}, claim)
+	assert.EqualError(t, c.MonitorCheck(), "consumer is lagging behind on PostConceptAnnotations/0 by 2 messages")
+
+	claim.highWaterMark = 1
+	assert.NoError(t, c.MonitorCheck(), "a lag within the tolerance is healthy")
+}
+
+func TestPartitionOffsetsLag(t *testing.T) {
+	messages := []kafka.FTMessage{
+		ftMessage(t, "tid_1", "\r\n"),
+		ftMessage(t, "tid_2", "\r\n"),
+		ftMessage(t, "tid_3", "\r\n"),
+	}
+
+	t.Run("counts the messages from the first one not processed", func(t *testing.T) {
+		claim := newFakeClaimAt(1041, 1250, messages...)
+		offsets := newPartitionOffsets(&fakeSession{}, claim)
+		assert.Equal(t, int64(209), offsets.lag(), "nothing processed yet")
+
+		var processed []func()
+		for message := range claim.Messages() {
+			processed = append(processed, offsets.add(message))
+		}
+		assert.Equal(t, int64(209), offsets.lag(), "handed over but not processed")
+
+		processed[1]()
+		assert.Equal(t, int64(209), offsets.lag(), "an earlier message is still being processed")
+		processed[0]()
+		assert.Equal(t, int64(207), offsets.lag())
+		processed[2]()
+		assert.Equal(t, int64(206), offsets.lag())
+	})
+
+	t.Run("is unknown before the first message when consuming from the newest offset", func(t *testing.T) {
+		claim := newFakeClaimAt(sarama.OffsetNewest, 1250)
+		offsets := newPartitionOffsets(&fakeSession{}, claim)
+		assert.Equal(t, int64(0), offsets.lag())
+
+		processed := offsets.add(&sarama.ConsumerMessage{Offset: 1249, Value: []byte(messages[0].Build())})
+		assert.Equal(t, int64(1), offsets.lag())
+		processed()
+		assert.Equal(t, int64(0), offsets.lag())
+	})
+}
+
+func TestOrderedConsumerStartAsyncDoesNotWaitForKafka(t *testing.T) {
+	config := kafka.ConsumerConfig{BrokersConnectionString: "127.0.0.1:1", ConsumerGroup: "annotations-rw", ConnectionRetryInterval: 10 * time.Millisecond}
+	c := newOrderedConsumer(config, []string{"PostConceptAnnotations"}, 0, 10, logger.NewUPPInfoLogger("annotations-rw"))
+
+	started := make(chan struct{})
+	go func() {
+		c.StartAsync(func(message kafka.FTMessage, done func()) { done() })
+		close(started)
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("StartAsync should return while Kafka cannot be reached")
+	}
+
+	assert.True(t, errors.Is(c.ConnectivityCheck(), kafka.ErrConsumerNotConnected))
+	assert.True(t, errors.Is(c.MonitorCheck(), kafka.ErrMonitorNotConnected))
+	assert.NoError(t, c.Close(), "closing stops connecting")
+}
+
+//go:linkname kafkaClientParseFTMessage github.com/Financial-Times/kafka-client-go/v3.rawToFTMessage
+func kafkaClientParseFTMessage(raw []byte) kafka.FTMessage
+
+// ftMessage returns a message as published by the forwarder, with the body of the example message
+func ftMessage(t *testing.T, tid string, newLine string) kafka.FTMessage {
+	body, err := os.ReadFile("exampleAnnotationsMessage.json")
+	require.NoError(t, err)
+	headers := forwarder.CreateHeaders(tid, "http://cmdb.ft.com/systems/pac", "FB:kcwQnrEEnFpfSJ2PtiykK/JNh8oBozhIkA==")
+	message := kafka.NewFTMessage(headers, strings.ReplaceAll(string(body), "\n", newLine))
+	return message
+}
+
+func TestParseFTMessageReadsMessagesLikeKafkaClient(t *testing.T) {
+	published := ftMessage(t, "tid_sample", "\r\n")
+	unix := ftMessage(t, "tid_sample", "\n")
+	unixRaw := strings.ReplaceAll(unix.Build(), "\r\n", "\n")
+
+	tests := map[string]string{
+		"published message":            published.Build(),
+		"unix line endings":            unixRaw,
+		"surrounding blank lines":      "FTMSG/1.0\r\nX-Request-Id: tid_sample\r\n\r\n\r\n  " + published.Body + "\r\n\r\n",
+		"header with other characters": "FTMSG/1.0\r\nX-Request-Id: tid_sample\r\nDead-Letter-Reason: invalid \"annotations\", see (logs)\r\n\r\n" + published.Body,
+		"malformed header lines":       "FTMSG/1.0\r\nnot a header\r\n: no name\r\nX-Request-Id:tid_sample\r\n\r\n{}",
+		"no body":                      "FTMSG/1.0\r\nX-Request-Id: tid_sample\r\n",
+		"no headers":                   "\r\n\r\n" + published.Body,
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, kafkaClientParseFTMessage([]byte(raw)), parseFTMessage([]byte(raw)))
+		})
+	}
+
+	parsed := parseFTMessage([]byte(published.Build()))
+	assert.Equal(t, published.Headers, parsed.Headers, "the headers of the forwarder should be read as they were published")
+	assert.JSONEq(t, published.Body, parsed.Body)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v3"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+)
+
+// Headers added to the original headers of a dead-lettered message
+const (
+	deadLetterReasonHeader    = "Dead-Letter-Reason"
+	deadLetterStageHeader     = "Dead-Letter-Stage"
+	deadLetterTimestampHeader = "Dead-Letter-Timestamp"
+)
+
+// Stages of the queue handler at which a message can fail, reported in the Dead-Letter-Stage header
+const (
+	stageHeaders    = "headers"
+	stageUnmarshal  = "unmarshal"
+	stageValidation = "validation"
+	stageWrite      = "write"
+)
+
+// maxDeadLetterReasonLength caps the Dead-Letter-Reason header, as errors can embed whole payloads
+const maxDeadLetterReasonLength = 500
+
+// invalidHeaderChars matches the characters kafka-client-go cannot read back from a header value
+var invalidHeaderChars = regexp.MustCompile(`[^\w\-:/.+;= ]+`)
+
+type messageProducer interface {
+	SendMessage(message kafka.FTMessage) error
+}
+
+// deadLetterQueue publishes the messages the queue handler cannot process to a dead-letter topic,
+// so that they can be replayed once the cause of the failure is fixed
+type deadLetterQueue struct {
+	producer messageProducer
+	now      func() time.Time
+}
+
+func newDeadLetterQueue(producer messageProducer) *deadLetterQueue {
+	return &deadLetterQueue{producer: producer, now: time.Now}
+}
+
+// send publishes a message with its original body and headers, adding the reason, stage and time of the failure
+func (d *deadLetterQueue) send(message kafka.FTMessage, stage string, reason error) error {
+	headers := make(map[string]string, len(message.Headers)+3)
+	for name, value := range message.Headers {
+		headers[name] = value
+	}
+	headers[deadLetterReasonHeader] = headerValue(reason.Error())
+	headers[deadLetterStageHeader] = stage
+	headers[deadLetterTimestampHeader] = d.now().Format(messageTimestampFormat)
+	return d.producer.SendMessage(kafka.NewFTMessage(headers, message.Body))
+}
+
+// headerValue turns an error message into a single line header value which survives the round trip through Kafka
+func headerValue(s string) string {
+	s = strings.Join(strings.Fields(invalidHeaderChars.ReplaceAllString(s, " ")), " ")
+	if len(s) > maxDeadLetterReasonLength {
+		s = s[:maxDeadLetterReasonLength]
+	}
+	return s
+}
+
+// deadLetteredBefore tells if a message was dead-lettered before the given time,
+// treating messages without a readable Dead-Letter-Timestamp as old ones
+func deadLetteredBefore(message kafka.FTMessage, t time.Time) bool {
+	deadLettered, err := time.Parse(messageTimestampFormat, message.Headers[deadLetterTimestampHeader])
+	return err != nil || deadLettered.Before(t)
+}
+
+// withoutDeadLetterHeaders returns the message as it was before being dead-lettered
+func withoutDeadLetterHeaders(message kafka.FTMessage) kafka.FTMessage {
+	headers := make(map[string]string, len(message.Headers))
+	for name, value := range message.Headers {
+		if name != deadLetterReasonHeader && name != deadLetterStageHeader && name != deadLetterTimestampHeader {
+			headers[name] = value
+		}
+	}
+	return kafka.NewFTMessage(headers, message.Body)
+}
+
+// replayDeadLetters feeds the messages consumed by qh, which reads the dead-letter topic, through the normal pipeline.
+// Messages failing again are dead-lettered again; those dead-lettered after the replay started are skipped,
+// so they are not replayed over and over. The replay stops once no message has been received for idleTimeout,
+// or when the process is signalled.
+func replayDeadLetters(qh *queueHandler, idleTimeout time.Duration, log *logger.UPPLogger) {
+	started := time.Now()
+	received := make(chan struct{}, 1)
+	var replayed, skipped int64
+	qh.consumer.Start(func(message kafka.FTMessage) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+
+		if !deadLetteredBefore(message, started) {
+			atomic.AddInt64(&skipped, 1)
+			return
+		}
+		log.WithTransactionID(message.Headers["X-Request-Id"]).
+			WithField("stage", message.Headers[deadLetterStageHeader]).
+			WithField("reason", message.Headers[deadLetterReasonHeader]).
+			Info("Replaying dead-lettered message")
+		qh.handleMessage(withoutDeadLetterHeaders(message))
+		atomic.AddInt64(&replayed, 1)
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+	for done := false; !done; {
+		select {
+		case <-received:
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(idleTimeout)
+		case <-idle.C:
+			log.Infof("No dead-lettered message received for %s, stopping the replay", idleTimeout)
+			done = true
+		case <-signals:
+			log.Info("Replay interrupted")
+			done = true
+		}
+	}
+
+	if err := qh.consumer.Close(); err != nil {
+		log.WithError(err).Warn("Could not close the dead-letter consumer")
+	}
+	log.Infof("Replayed %d dead-lettered messages, skipped %d dead-lettered during the replay", atomic.LoadInt64(&replayed), atomic.LoadInt64(&skipped))
+}
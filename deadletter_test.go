@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v3"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeadLetterReasonSurvivesKafka(t *testing.T) {
+	producer := new(mockProducer)
+	var sent kafka.FTMessage
+	producer.On("SendMessage", mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(kafka.FTMessage)
+	}).Return(nil)
+	queue := &deadLetterQueue{producer: producer, now: func() time.Time { return time.Date(2024, 3, 1, 10, 15, 30, 0, time.UTC) }}
+
+	message := kafka.NewFTMessage(map[string]string{"X-Request-Id": "tid_sample"}, `{"uuid":"12345"}`)
+	err := queue.send(message, stageValidation, errors.New("I[#/annotations/0] \"predicate\" (is required),\nmissing id"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "I /annotations/0 predicate is required missing id", sent.Headers[deadLetterReasonHeader])
+	assert.Equal(t, stageValidation, sent.Headers[deadLetterStageHeader])
+	assert.Equal(t, "2024-03-01T10:15:30.000Z", sent.Headers[deadLetterTimestampHeader])
+	assert.Equal(t, "tid_sample", sent.Headers["X-Request-Id"])
+	assert.Equal(t, message.Body, sent.Body)
+	assert.Equal(t, map[string]string{"X-Request-Id": "tid_sample"}, message.Headers, "The original message should be left untouched")
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	headers := map[string]string{
+		"X-Request-Id":            "tid_sample",
+		deadLetterReasonHeader:    "missing Origin-System-Id header",
+		deadLetterStageHeader:     stageHeaders,
+		deadLetterTimestampHeader: time.Now().Add(-time.Hour).Format(messageTimestampFormat),
+	}
+	producer := new(mockProducer)
+	producer.On("SendMessage", mock.Anything).Return(nil)
+	log := logger.NewUPPInfoLogger("annotations-rw")
+
+	qh := &queueHandler{consumer: mockConsumer{message: kafka.NewFTMessage(headers, "{}")}, log: log, deadLetters: newDeadLetterQueue(producer)}
+	replayDeadLetters(qh, 10*time.Millisecond, log)
+	producer.AssertNumberOfCalls(t, "SendMessage", 1)
+	replayed := producer.Calls[0].Arguments.Get(0).(kafka.FTMessage)
+	assert.Equal(t, "missing Origin-System-Id header", replayed.Headers[deadLetterReasonHeader], "The message should be replayed without its old dead-letter headers")
+	assert.NotEqual(t, headers[deadLetterTimestampHeader], replayed.Headers[deadLetterTimestampHeader])
+
+	headers[deadLetterTimestampHeader] = time.Now().Add(time.Hour).Format(messageTimestampFormat)
+	qh.consumer = mockConsumer{message: kafka.NewFTMessage(headers, "{}")}
+	replayDeadLetters(qh, 10*time.Millisecond, log)
+	producer.AssertNumberOfCalls(t, "SendMessage", 1)
+}
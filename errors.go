@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// Machine-readable codes of error responses. Codes ending in _ERROR or _FAILED, other than VALIDATION_FAILED
+// and PRECONDITION_FAILED, are failures of the service or its dependencies which may succeed when retried.
+const (
+	codeInvalidRequest         = "INVALID_REQUEST"
+	codeUnsupportedContentType = "UNSUPPORTED_CONTENT_TYPE"
+	codeUnsupportedLifecycle   = "UNSUPPORTED_LIFECYCLE"
+	codeValidationFailed       = "VALIDATION_FAILED"
+	codeTooManyEntries         = "TOO_MANY_ENTRIES"
+	codeNotFound               = "NOT_FOUND"
+	codePreconditionFailed     = "PRECONDITION_FAILED"
+	codeStaleWrite             = "STALE_WRITE"
+	codeNeo4jError             = "NEO4J_ERROR"
+	codeForwardingFailed       = "FORWARDING_FAILED"
+	codeQueueFull              = "QUEUE_FULL"
+	codeInternalError          = "INTERNAL_ERROR"
+)
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details lists every problem found when validating a payload
+	Details       []validationViolation `json:"details,omitempty"`
+	TransactionID string                `json:"transactionId"`
+}
+
+// writeJSONError responds with the status code and an errorResponse carrying the transaction ID of the request
+func writeJSONError(w http.ResponseWriter, r *http.Request, statusCode int, code string, errorMsg string, details ...validationViolation) {
+	body := errorResponse{
+		Code:          code,
+		Message:       errorMsg,
+		Details:       details,
+		TransactionID: transactionidutils.GetTransactionIDFromRequest(r),
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -10,6 +10,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// Message types of the messages sent by the Forwarder, in their Message-Type header
+const (
+	// MessageType is the type of the messages holding the annotations written for a piece of content
+	MessageType = "concept-annotation"
+	// DeletionMessageType is the type of the messages telling that the annotations of a lifecycle have been deleted
+	DeletionMessageType = "concept-annotation-deletion"
+)
+
 // The outputMessage represents the structure of the JSON object that is written in the body of the message
 // sent to Kafka by the SendMessage method of Forwarder.
 //
@@ -26,6 +34,7 @@ type outputMessage struct {
 // QueueForwarder is the interface implemented by types that can send annotation messages to a queue.
 type QueueForwarder interface {
 	SendMessage(transactionID string, originSystem string, bookmark string, platformVersion string, uuid string, annotations interface{}, publication []string) error
+	SendDeletion(transactionID string, originSystem string, bookmark string, platformVersion string, uuid string) error
 }
 
 type kafkaProducer interface {
@@ -41,7 +50,7 @@ type Forwarder struct {
 // SendMessage marshals an annotations payload using the outputMessage format and sends it to a Kafka.
 func (f Forwarder) SendMessage(transactionID string, originSystem string, bookmark string, platformVersion string, uuid string, annotations interface{}, publication []string) error {
 	headers := CreateHeaders(transactionID, originSystem, bookmark)
-	body, err := f.prepareBody(platformVersion, uuid, annotations, headers["Message-Timestamp"], publication)
+	body, err := f.prepareBody(platformVersion, uuid, annotations, headers["Message-Timestamp"], publication, false)
 	if err != nil {
 		return err
 	}
@@ -49,7 +58,22 @@ func (f Forwarder) SendMessage(transactionID string, originSystem string, bookma
 	return f.Producer.SendMessage(kafka.NewFTMessage(headers, body))
 }
 
-func (f Forwarder) prepareBody(platformVersion string, uuid string, anns interface{}, lastModified string, publication []string) (string, error) {
+// SendDeletion sends a message telling that the annotations of a lifecycle have been deleted for a piece of content.
+// Its Message-Type is DeletionMessageType, so that consumers can tell it apart from annotations having been replaced
+// by an empty set without reading the body, which has the format of the messages of SendMessage with an empty list
+// of annotations and the payload marked as "deleted".
+func (f Forwarder) SendDeletion(transactionID string, originSystem string, bookmark string, platformVersion string, uuid string) error {
+	headers := CreateHeaders(transactionID, originSystem, bookmark)
+	headers["Message-Type"] = DeletionMessageType
+	body, err := f.prepareBody(platformVersion, uuid, []interface{}{}, headers["Message-Timestamp"], nil, true)
+	if err != nil {
+		return err
+	}
+
+	return f.Producer.SendMessage(kafka.NewFTMessage(headers, body))
+}
+
+func (f Forwarder) prepareBody(platformVersion string, uuid string, anns interface{}, lastModified string, publication []string, deleted bool) (string, error) {
 	wrappedMsg := outputMessage{
 		Payload: map[string]interface{}{
 			strings.ToLower(f.MessageType): anns,
@@ -60,6 +84,9 @@ func (f Forwarder) prepareBody(platformVersion string, uuid string, anns interfa
 		ContentURI:   "http://" + platformVersion + "." + strings.ToLower(f.MessageType) + "-rw-neo4j.svc.ft.com/annotations/" + uuid,
 		LastModified: lastModified,
 	}
+	if deleted {
+		wrappedMsg.Payload["deleted"] = true
+	}
 
 	// Given the type of data we are marshalling, there is no possible input that can trigger an error here
 	// but we are handling errors just to be principled
@@ -80,7 +107,7 @@ func CreateHeaders(transactionID string, originSystem string, bookmark string) m
 		"X-Request-Id":      transactionID,
 		"Message-Timestamp": time.Now().Format(dateFormat),
 		"Message-Id":        messageUUID.String(),
-		"Message-Type":      "concept-annotation",
+		"Message-Type":      MessageType,
 		"Content-Type":      "application/json",
 		"Origin-System-Id":  originSystem,
 		"Neo4j-Bookmark":    bookmark,
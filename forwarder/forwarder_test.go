@@ -89,6 +89,36 @@ func TestSendMessage(t *testing.T) {
 	}
 }
 
+func TestSendDeletion(t *testing.T) {
+	const expectedDeletionOutputBody = `{"payload":{"annotations":[],"deleted":true,"lastModified":"%s","publication":null,"uuid":"3a636e78-5a47-11e7-9bc8-8055f264aa8b"},"contentUri":"http://pac.annotations-rw-neo4j.svc.ft.com/annotations/3a636e78-5a47-11e7-9bc8-8055f264aa8b","lastModified":"%[1]s"}`
+
+	p := new(mockProducer)
+	f := forwarder.Forwarder{
+		Producer:    p,
+		MessageType: "Annotations",
+	}
+
+	err := f.SendDeletion(transactionID, originSystem, bookmark, "pac", "3a636e78-5a47-11e7-9bc8-8055f264aa8b")
+	if err != nil {
+		t.Error("Error sending deletion")
+	}
+
+	res := p.getLastMessage()
+	expectedBody := fmt.Sprintf(expectedDeletionOutputBody, res.Headers["Message-Timestamp"])
+	if res.Body != expectedBody {
+		t.Errorf("Unexpected Kafka message processed, expected: \n`%s`\n\n but recevied: \n`%s`", expectedBody, res.Body)
+	}
+	if res.Headers["Origin-System-Id"] != originSystem {
+		t.Errorf("Unexpected Kafka Origin-System-Id, expected `%s` but recevied `%s`", originSystem, res.Headers["Origin-System-Id"])
+	}
+	if res.Headers["Neo4j-Bookmark"] != bookmark {
+		t.Errorf("Unexpected Kafka Neo4j-Bookmark, expected `%s` but recevied `%s`", bookmark, res.Headers["Neo4j-Bookmark"])
+	}
+	if res.Headers["Message-Type"] != forwarder.DeletionMessageType {
+		t.Errorf("Unexpected Kafka Message-Type, expected `%s` but recevied `%s`", forwarder.DeletionMessageType, res.Headers["Message-Type"])
+	}
+}
+
 func TestCreateHeaders(t *testing.T) {
 	headers := forwarder.CreateHeaders(transactionID, originSystem, bookmark)
 
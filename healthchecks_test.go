@@ -34,7 +34,7 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_Health_Success(
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: mockConsumer{}}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 }
 
@@ -44,7 +44,7 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_Health_Annotati
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: mockConsumer{}}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 	assert.Contains(suite.T(), rec.Body.String(), `"ok":false`)
 }
@@ -55,7 +55,7 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_Health_Consumer
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: mockConsumer{err: errors.New("consumer error")}}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 	assert.Contains(suite.T(), rec.Body.String(), `"ok":false`)
 }
@@ -66,7 +66,7 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_GTG_Success() {
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: mockConsumer{}}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 }
 
@@ -76,7 +76,7 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_GTG_Annotations
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: mockConsumer{}}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	fmt.Println(rec.Body.String())
 	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
 }
@@ -87,7 +87,7 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_GTG_ConsumerNot
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: mockConsumer{err: errors.New("consumer error")}}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
 }
 
@@ -97,6 +97,6 @@ func (suite *HealthCheckHandlerTestSuite) TestHealthCheckHandler_GTG_NilConsumer
 	assert.NoError(suite.T(), err, "Unexpected error")
 	healthCheckHandler := healthCheckHandler{annotationsService: suite.annotationsService, consumer: nil}
 	rec := httptest.NewRecorder()
-	router(&suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
+	specRouter(suite.T(), &suite.httpHandler, &healthCheckHandler, suite.log).ServeHTTP(rec, req)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 }
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
 	"github.com/Financial-Times/annotations-rw-neo4j/v4/forwarder"
@@ -17,12 +23,84 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
 const (
 	lifecyclePropertyName = "annotationLifecycle"
 	bookmarkHeader        = "Neo4j-Bookmark"
 	publicationHeader     = "Publication"
 )
 
+const (
+	// importBatchSize is the number of content items written to neo4j in a single transaction while importing
+	importBatchSize = 100
+	// maxImportLineSize caps the size of a single record of an import stream
+	maxImportLineSize = 10 * 1024 * 1024
+)
+
+// exportPageSize is the number of content items read from neo4j at a time while exporting a lifecycle
+const exportPageSize = 100
+
+// maxBulkEntries caps the number of content items accepted by a single bulk request
+const maxBulkEntries = 1000
+
+// maxReadManyUUIDs caps the number of content UUIDs accepted by a single batch read
+const maxReadManyUUIDs = 1000
+
+// bulkEntry holds the annotations for a single piece of content in a bulk request
+type bulkEntry struct {
+	UUID        string        `json:"uuid"`
+	Publication []string      `json:"publication,omitempty"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// bulkResult reports what happened to a single bulkEntry
+type bulkResult struct {
+	UUID   string `json:"uuid"`
+	Status int    `json:"status"`
+	// Code is the machine-readable reason of a failure, as in errorResponse
+	Code     string                `json:"code,omitempty"`
+	Message  string                `json:"message"`
+	Details  []validationViolation `json:"details,omitempty"`
+	Bookmark string                `json:"bookmark,omitempty"`
+}
+
+// importResult reports what happened to a single line of an import stream
+type importResult struct {
+	Line int `json:"line"`
+	bulkResult
+}
+
+// importRecord is a line of an import stream, waiting for its batch to be written unless
+// its entry is nil, the line having been rejected.
+type importRecord struct {
+	result importResult
+	entry  *bulkEntry
+}
+
+// patchRequest lists the annotations to add to and remove from the stored set of a lifecycle
+type patchRequest struct {
+	Add    []interface{}               `json:"add"`
+	Remove []annotations.AnnotationRef `json:"remove"`
+}
+
+// validationResult is the outcome of a dry-run validation of an annotations payload
+type validationResult struct {
+	Valid      bool                  `json:"valid"`
+	Violations []validationViolation `json:"violations"`
+}
+
+// validationViolation describes a single reason for rejecting a payload. Index and Pointer, a JSON pointer into
+// the request body, point at the offending annotation and are left out for problems with the request as a whole.
+type validationViolation struct {
+	Index   *int   `json:"index,omitempty"`
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+}
+
 // service def
 type httpHandler struct {
 	validator          jsonValidator
@@ -32,6 +110,10 @@ type httpHandler struct {
 	lifecycleMap       map[string]string
 	messageType        string
 	log                *logger.UPPLogger
+	// forwardUnchanged decides if writes leaving the stored annotations as they are should still be forwarded
+	forwardUnchanged bool
+	// jobs queues the PUTs processed asynchronously, which are not supported when nil
+	jobs *jobQueue
 }
 
 // GetAnnotations returns a view of the annotations written - it is NOT the public annotations API, and
@@ -42,35 +124,145 @@ func (hh *httpHandler) GetAnnotations(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	uuid := vars["uuid"]
 	if uuid == "" {
-		writeJSONError(w, "uuid required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
 		return
 	}
 
 	lifecycle := vars[lifecyclePropertyName]
 	if lifecycle == "" {
-		writeJSONError(w, "annotationLifecycle required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "annotationLifecycle required")
 		return
 	} else if _, ok := hh.lifecycleMap[lifecycle]; !ok {
-		writeJSONError(w, "annotationLifecycle not supported by this application", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+		return
+	}
+
+	filter, err := readFilterParams(r)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
 		return
 	}
 
 	tid := transactionidutils.GetTransactionIDFromRequest(r)
 	bookmark := r.Header.Get(bookmarkHeader)
-	annotations, found, err := hh.annotationsService.Read(uuid, bookmark, lifecycle)
+	anns, version, found, err := hh.annotationsService.Read(uuid, bookmark, lifecycle, filter)
+	if errors.Is(err, annotations.ErrUnknownPredicate) {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
 	if err != nil {
 		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed getting annotations")
 		msg := fmt.Sprintf("Error getting annotations (%v)", err)
-		writeJSONError(w, msg, http.StatusServiceUnavailable)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, msg)
 		return
 	}
 	if !found {
-		writeJSONError(w, fmt.Sprintf("No annotations found for content with uuid %s.", uuid), http.StatusNotFound)
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("No annotations found for content with uuid %s.", uuid))
 		return
 	}
-	annotationJson, _ := json.Marshal(annotations)
+	annotationJson, _ := json.Marshal(anns)
 	hh.log.Debugf("Annotations for content (uuid:%s): %s\n", uuid, annotationJson)
+	// a filtered response is not the representation If-Match is compared against, so it gets no ETag
+	if filter.IsEmpty() {
+		w.Header().Set("ETag", `"`+version+`"`)
+	}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(anns)
+	if err != nil {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("writing response")
+	}
+}
+
+// readFilterParams reads the predicate, publication, minConfidence and minRelevance query parameters of GetAnnotations
+func readFilterParams(r *http.Request) (annotations.ReadFilter, error) {
+	query := r.URL.Query()
+	filter := annotations.ReadFilter{
+		Predicate:   query.Get("predicate"),
+		Publication: query.Get("publication"),
+	}
+	for name, score := range map[string]**float64{"minConfidence": &filter.MinConfidence, "minRelevance": &filter.MinRelevance} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		min, err := strconv.ParseFloat(value, 64)
+		if err != nil || min < 0 || min > 1 {
+			return annotations.ReadFilter{}, fmt.Errorf("%s must be a number between 0 and 1", name)
+		}
+		*score = &min
+	}
+	return filter, nil
+}
+
+// GetAnnotationsSummary gives an overview of the annotations of every configured lifecycle for a piece of content:
+// how many there are by predicate, and the platformVersion, publication and time of the last write of each lifecycle.
+func (hh *httpHandler) GetAnnotationsSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	uuid := mux.Vars(r)["uuid"]
+	if uuid == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
+		return
+	}
+
+	lifecycles := make([]string, 0, len(hh.lifecycleMap))
+	for lifecycle := range hh.lifecycleMap {
+		lifecycles = append(lifecycles, lifecycle)
+	}
+	sort.Strings(lifecycles)
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	summaries, found, err := hh.annotationsService.Summary(uuid, r.Header.Get(bookmarkHeader), lifecycles)
+	if err != nil {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed getting annotations summary")
+		msg := fmt.Sprintf("Error getting annotations summary (%v)", err)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, msg)
+		return
+	}
+	if !found {
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("No annotations found for content with uuid %s.", uuid))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(summaries)
+	if err != nil {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("writing response")
+	}
+}
+
+// GetAllAnnotations returns the annotations of every configured lifecycle for a piece of content, grouped by
+// lifecycle. Like GetAnnotations, this is a view of what has been written and not the public annotations API.
+func (hh *httpHandler) GetAllAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	uuid := mux.Vars(r)["uuid"]
+	if uuid == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
+		return
+	}
+
+	lifecycles := make([]string, 0, len(hh.lifecycleMap))
+	for lifecycle := range hh.lifecycleMap {
+		lifecycles = append(lifecycles, lifecycle)
+	}
+	sort.Strings(lifecycles)
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	bookmark := r.Header.Get(bookmarkHeader)
+	annotations, found, err := hh.annotationsService.ReadAll(uuid, bookmark, lifecycles)
+	if err != nil {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed getting annotations")
+		msg := fmt.Sprintf("Error getting annotations (%v)", err)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, msg)
+		return
+	}
+	if !found {
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("No annotations found for content with uuid %s.", uuid))
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(annotations)
 	if err != nil {
@@ -78,6 +270,157 @@ func (hh *httpHandler) GetAnnotations(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ReadManyAnnotations returns the annotations of a lifecycle for the content UUIDs in the request body, keyed by
+// content UUID, using a single read. Content without annotations is left out rather than failing the request.
+func (hh *httpHandler) ReadManyAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := isContentTypeJSON(r); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedContentType, err.Error())
+		return
+	}
+
+	lifecycle := mux.Vars(r)[lifecyclePropertyName]
+	if _, ok := hh.lifecycleMap[lifecycle]; !ok {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+		return
+	}
+
+	var uuids []string
+	err := json.NewDecoder(r.Body).Decode(&uuids)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Error (%v) parsing content UUIDs", err))
+		return
+	}
+	if len(uuids) > maxReadManyUUIDs {
+		writeJSONError(w, r, http.StatusRequestEntityTooLarge, codeTooManyEntries, fmt.Sprintf("Batch reads are limited to %d content UUIDs", maxReadManyUUIDs))
+		return
+	}
+	for i, uuid := range uuids {
+		if uuid == "" {
+			idx := i
+			writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required",
+				validationViolation{Index: &idx, Pointer: fmt.Sprintf("/%d", i), Message: "uuid required"})
+			return
+		}
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	anns, err := hh.annotationsService.ReadMany(uuids, r.Header.Get(bookmarkHeader), lifecycle)
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithError(err).Error("failed getting annotations")
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, fmt.Sprintf("Error getting annotations (%v)", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(anns)
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithError(err).Error("writing response")
+	}
+}
+
+// GetConceptContent lists the content annotated with a concept, a page at a time. The lifecycle (repeatable)
+// and predicate query parameters narrow down the annotations considered, and the cursor parameter selects the page.
+func (hh *httpHandler) GetConceptContent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	conceptUUID := mux.Vars(r)["conceptUUID"]
+	if conceptUUID == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "conceptUUID required")
+		return
+	}
+
+	query := r.URL.Query()
+	lifecycles := query["lifecycle"]
+	for _, lifecycle := range lifecycles {
+		if _, ok := hh.lifecycleMap[lifecycle]; !ok {
+			writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+			return
+		}
+	}
+	if len(lifecycles) == 0 {
+		for lifecycle := range hh.lifecycleMap {
+			lifecycles = append(lifecycles, lifecycle)
+		}
+		sort.Strings(lifecycles)
+	}
+
+	limit := defaultPageLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("limit must be a number between 1 and %d", maxPageLimit))
+			return
+		}
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	bookmark := r.Header.Get(bookmarkHeader)
+	page, err := hh.annotationsService.ReadConceptContent(conceptUUID, bookmark, lifecycles, query.Get("predicate"), query.Get("cursor"), limit)
+	if errors.Is(err, annotations.ErrUnknownPredicate) || errors.Is(err, annotations.ErrInvalidCursor) {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		hh.log.WithUUID(conceptUUID).WithTransactionID(tid).WithError(err).Error("failed getting content for concept")
+		msg := fmt.Sprintf("Error getting content for concept (%v)", err)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		hh.log.WithUUID(conceptUUID).WithTransactionID(tid).WithError(err).Error("writing response")
+	}
+}
+
+// DeleteAllAnnotations deletes the annotations of every configured lifecycle for a piece of content in one go,
+// responding with the lifecycles that had annotations
+func (hh *httpHandler) DeleteAllAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	uuid := mux.Vars(r)["uuid"]
+	if uuid == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
+		return
+	}
+
+	lifecycles := make([]string, 0, len(hh.lifecycleMap))
+	for lifecycle := range hh.lifecycleMap {
+		lifecycles = append(lifecycles, lifecycle)
+	}
+	sort.Strings(lifecycles)
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	deleted, bookmark, err := hh.annotationsService.DeleteAll(uuid, lifecycles)
+	if err != nil {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed deleting annotations")
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, err.Error())
+		return
+	}
+	if len(deleted) == 0 {
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("No annotations found for content with uuid %s.", uuid))
+		return
+	}
+	hh.log.WithUUID(uuid).WithTransactionID(tid).Infof("deleted annotations of lifecycles %v", deleted)
+
+	for _, lifecycle := range deleted {
+		if err = hh.forwardDeletion(tid, uuid, lifecycle, bookmark); err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, codeForwardingFailed, "Failed to forward message to queue")
+			return
+		}
+	}
+
+	w.Header().Add(bookmarkHeader, bookmark)
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(map[string][]string{"deleted": deleted})
+	if err != nil {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("writing response")
+	}
+}
+
 // DeleteAnnotations will delete all the annotations for a piece of content
 func (hh *httpHandler) DeleteAnnotations(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -85,30 +428,40 @@ func (hh *httpHandler) DeleteAnnotations(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	uuid := vars["uuid"]
 	if uuid == "" {
-		writeJSONError(w, "uuid required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
 		return
 	}
 
 	lifecycle := vars[lifecyclePropertyName]
 	if lifecycle == "" {
-		writeJSONError(w, "annotationLifecycle required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "annotationLifecycle required")
 		return
 	} else if _, ok := hh.lifecycleMap[lifecycle]; !ok {
-		writeJSONError(w, "annotationLifecycle not supported by this application", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
 		return
 	}
 
 	tid := transactionidutils.GetTransactionIDFromRequest(r)
-	found, bookmark, err := hh.annotationsService.Delete(uuid, lifecycle)
+	found, bookmark, err := hh.annotationsService.Delete(uuid, lifecycle, ifMatchVersions(r))
+	if errors.Is(err, annotations.ErrPreconditionFailed) {
+		hh.writePreconditionFailed(w, r, tid, uuid)
+		return
+	}
 	if err != nil {
 		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed deleting annotations")
-		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, err.Error())
 		return
 	}
 	if !found {
-		writeJSONError(w, fmt.Sprintf("No annotations found for content with uuid %s.", uuid), http.StatusNotFound)
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("No annotations found for content with uuid %s.", uuid))
 		return
 	}
+
+	if err = hh.forwardDeletion(tid, uuid, lifecycle, bookmark); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, codeForwardingFailed, "Failed to forward message to queue")
+		return
+	}
+
 	w.Header().Add(bookmarkHeader, bookmark)
 	w.WriteHeader(http.StatusNoContent)
 	_, err = w.Write(jsonMessage(fmt.Sprintf("Annotations for content %s deleted", uuid)))
@@ -121,88 +474,334 @@ func (hh *httpHandler) CountAnnotations(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	lifecycle := vars[lifecyclePropertyName]
 	if lifecycle == "" {
-		writeJSONError(w, "annotationLifecycle required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "annotationLifecycle required")
 		return
 	} else if _, ok := hh.lifecycleMap[lifecycle]; !ok {
-		writeJSONError(w, "annotationLifecycle not supported by this application", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
 		return
 	}
 
 	platformVersion, found := hh.lifecycleMap[lifecycle]
 	if !found {
-		writeJSONError(w, "platformVersion not found for this annotation lifecycle", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "platformVersion not found for this annotation lifecycle")
 		return
 	}
 
 	bookmark := r.Header.Get(bookmarkHeader)
-	count, err := hh.annotationsService.Count(lifecycle, bookmark, platformVersion)
+	groupBy := r.URL.Query().Get("groupBy")
+	var count interface{}
+	var err error
+	if groupBy == "" {
+		count, err = hh.annotationsService.Count(lifecycle, bookmark, platformVersion)
+	} else {
+		count, err = hh.annotationsService.CountBy(lifecycle, bookmark, platformVersion, groupBy)
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 
+	if errors.Is(err, annotations.ErrUnsupportedGroupBy) {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
 	if err != nil {
-		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, err.Error())
 		return
 	}
 	enc := json.NewEncoder(w)
 
 	if err := enc.Encode(count); err != nil {
-		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		writeJSONError(w, r, http.StatusInternalServerError, codeInternalError, err.Error())
 		return
 	}
 }
 
+// ExportAnnotations streams the annotations of every piece of content written by a lifecycle as newline-delimited JSON,
+// one {uuid, publication, annotations} record per line. Content is read from neo4j a page at a time in the background
+// while the previous page is written out, so the whole lifecycle is never held in memory.
+// As the status code is sent with the first line, a failure mid-stream is reported as a final {"error": ...} line.
+func (hh *httpHandler) ExportAnnotations(w http.ResponseWriter, r *http.Request) {
+	lifecycle := mux.Vars(r)[lifecyclePropertyName]
+	if _, ok := hh.lifecycleMap[lifecycle]; !ok {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	bookmark := r.Header.Get(bookmarkHeader)
+	records, errs := hh.exportPages(r.Context(), lifecycle, bookmark)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for record := range records {
+		if err := enc.Encode(record); err != nil {
+			hh.log.WithTransactionID(tid).WithError(err).Error("writing export response")
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if err := <-errs; err != nil {
+		hh.log.WithTransactionID(tid).WithError(err).Errorf("exporting annotations for lifecycle %s failed", lifecycle)
+		_ = enc.Encode(map[string]string{"error": err.Error()})
+	}
+}
+
+// exportPages reads the content of a lifecycle from neo4j page by page in the background. The records channel is
+// closed once all the content has been read, a read fails or ctx is done, after which errs yields the outcome.
+func (hh *httpHandler) exportPages(ctx context.Context, lifecycle string, bookmark string) (<-chan annotations.ContentAnnotations, <-chan error) {
+	records := make(chan annotations.ContentAnnotations, exportPageSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(records)
+
+		afterUUID := ""
+		for {
+			page, err := hh.annotationsService.ReadLifecyclePage(lifecycle, bookmark, afterUUID, exportPageSize)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, record := range page {
+				select {
+				case records <- record:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+			if len(page) < exportPageSize {
+				return
+			}
+			afterUUID = page[len(page)-1].UUID
+		}
+	}()
+
+	return records, errs
+}
+
+// ImportAnnotations reads newline-delimited JSON {uuid, publication, annotations} records, as produced by ExportAnnotations,
+// and writes the valid ones in batches of importBatchSize content items per transaction. Each written record is also
+// forwarded when the forward query parameter is true. The outcome of every line is streamed back as newline-delimited JSON.
+func (hh *httpHandler) ImportAnnotations(w http.ResponseWriter, r *http.Request) {
+	lifecycle := mux.Vars(r)[lifecyclePropertyName]
+	platformVersion, ok := hh.lifecycleMap[lifecycle]
+	if !ok {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+		return
+	}
+
+	forward, err := boolParam(r, "forward", false)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "forward must be true or false")
+		return
+	}
+
+	originSystem := hh.originSystemForLifecycle(lifecycle)
+	if forward && originSystem == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "No Origin-System-Id could be deduced from the lifecycle parameter")
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	rc := http.NewResponseController(w)
+	// results are written while the request body is still being read
+	_ = rc.EnableFullDuplex()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	var pending []importRecord
+	// invalid records are reported straight away, the valid ones once their batch has been written
+	report := func(records ...importRecord) error {
+		for _, record := range records {
+			if err := enc.Encode(record.result); err != nil {
+				return err
+			}
+		}
+		_ = rc.Flush()
+		return nil
+	}
+	flush := func() error {
+		hh.writeImportBatch(tid, lifecycle, platformVersion, originSystem, forward, pending)
+		err := report(pending...)
+		pending = pending[:0]
+		return err
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		record := importRecord{result: importResult{Line: line}}
+		var entry bulkEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			record.result.Status = http.StatusBadRequest
+			record.result.Code = codeInvalidRequest
+			record.result.Message = fmt.Sprintf("Error (%v) parsing import record", err)
+		} else if result, ok := hh.checkBulkEntry(tid, entry); !ok {
+			record.result.bulkResult = result
+		} else {
+			record.result.UUID = entry.UUID
+			record.entry = &entry
+		}
+
+		var err error
+		if record.entry == nil {
+			err = report(record)
+		} else if pending = append(pending, record); len(pending) == importBatchSize {
+			err = flush()
+		}
+		if err != nil {
+			hh.log.WithTransactionID(tid).WithError(err).Error("writing import response")
+			return
+		}
+	}
+	err = flush()
+	if err == nil && scanner.Err() != nil {
+		err = report(importRecord{result: importResult{
+			Line:       line + 1,
+			bulkResult: bulkResult{Status: http.StatusBadRequest, Code: codeInvalidRequest, Message: fmt.Sprintf("Error (%v) reading import stream", scanner.Err())},
+		}})
+	}
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithError(err).Error("writing import response")
+	}
+}
+
+// writeImportBatch writes the records of a batch in a single transaction and fills in their results. When the
+// transaction fails, the records are written one by one, so that only those which cannot be written fail.
+func (hh *httpHandler) writeImportBatch(tid, lifecycle, platformVersion, originSystem string, forward bool, batch []importRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	writes := make([]annotations.ContentWrite, 0, len(batch))
+	for _, record := range batch {
+		writes = append(writes, annotations.ContentWrite{
+			ContentUUID:     record.entry.UUID,
+			Lifecycle:       lifecycle,
+			PlatformVersion: platformVersion,
+			Publication:     toSliceOfInterface(record.entry.Publication),
+			Annotations:     record.entry.Annotations,
+		})
+	}
+
+	bookmarks := make([]string, len(batch))
+	errs := make([]error, len(batch))
+	bookmark, changed, err := hh.annotationsService.WriteBatch(writes)
+	if err == nil {
+		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).Infof("%d %s successfully written in Neo4j", len(writes), hh.messageType)
+		for i := range batch {
+			bookmarks[i] = bookmark
+		}
+	} else {
+		hh.log.WithTransactionID(tid).WithError(err).Warnf("Could not write a batch of %d records to Neo4j, writing them one by one", len(writes))
+		changed = make([]bool, len(writes))
+		for i, write := range writes {
+			bookmarks[i], changed[i], errs[i] = hh.annotationsService.Write(write.ContentUUID, write.Lifecycle, write.PlatformVersion, write.Publication, write.Annotations, write.LastModified, nil)
+			if errs[i] != nil {
+				hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(write.ContentUUID).WithError(errs[i]).Error("Error creating annotations")
+			}
+		}
+	}
+
+	for i := range batch {
+		record := &batch[i]
+		result := &record.result.bulkResult
+		if errs[i] != nil {
+			result.Status = http.StatusServiceUnavailable
+			result.Code = codeNeo4jError
+			result.Message = fmt.Sprintf("Error creating annotations (%v)", errs[i])
+			continue
+		}
+		result.Bookmark = bookmarks[i]
+		if !changed[i] {
+			hh.log.WithTransactionID(tid).WithUUID(record.entry.UUID).Infof("%s unchanged, skipped writing in Neo4j", hh.messageType)
+		}
+
+		if forward && hh.forwarder != nil && (changed[i] || hh.forwardUnchanged) {
+			hh.log.WithTransactionID(tid).WithUUID(record.entry.UUID).Debug("Forwarding message to the next queue")
+			ferr := hh.forwarder.SendMessage(tid, originSystem, bookmarks[i], platformVersion, record.entry.UUID, record.entry.Annotations, record.entry.Publication)
+			if ferr != nil {
+				hh.log.WithTransactionID(tid).WithUUID(record.entry.UUID).WithError(ferr).Error("Failed to forward message to queue")
+				result.Status = http.StatusInternalServerError
+				result.Code = codeForwardingFailed
+				result.Message = "Failed to forward message to queue"
+				continue
+			}
+		}
+
+		if !changed[i] {
+			result.Status = http.StatusOK
+			result.Message = fmt.Sprintf("Annotations for content %s unchanged", record.entry.UUID)
+			continue
+		}
+		result.Status = http.StatusCreated
+		result.Message = fmt.Sprintf("Annotations for content %s created", record.entry.UUID)
+	}
+}
+
 // PutAnnotations handles the replacement of a set of annotations for a given bit of content
 func (hh *httpHandler) PutAnnotations(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	if err := isContentTypeJSON(r); err != nil {
-		http.Error(w, string(jsonMessage(err.Error())), http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedContentType, err.Error())
 		return
 	}
 	vars := mux.Vars(r)
 	uuid := vars["uuid"]
 	if uuid == "" {
-		writeJSONError(w, "uuid required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
 		return
 	}
 
 	lifecycle := vars[lifecyclePropertyName]
 	if lifecycle == "" {
-		writeJSONError(w, "annotationLifecycle required for uuid %s"+uuid, http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("annotationLifecycle required for uuid %s", uuid))
 		return
 	}
 
 	platformVersion, ok := hh.lifecycleMap[lifecycle]
 	if !ok {
-		writeJSONError(w, "annotationLifecycle not supported by this application", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
 		return
 	}
 
-	var originSystem string
-	for k, v := range hh.originMap {
-		if v == lifecycle {
-			originSystem = k
-			break
-		}
-	}
+	originSystem := hh.originSystemForLifecycle(lifecycle)
 	if originSystem == "" {
-		writeJSONError(w, "No Origin-System-Id could be deduced from the lifecycle parameter", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "No Origin-System-Id could be deduced from the lifecycle parameter")
 		return
 	}
 
 	anns, err := decode(r.Body)
 	if err != nil {
 		msg := fmt.Sprintf("Error (%v) parsing annotation request", err)
-		writeJSONError(w, msg, http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, msg)
 		return
 	}
 
 	tid := transactionidutils.GetTransactionIDFromRequest(r)
-	for _, ann := range anns {
-		err = hh.validator.Validate(ann)
+	if violations := validateAll(hh.validator, anns, ""); len(violations) > 0 {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).Errorf("failed validating annotations: %d invalid", len(violations))
+		writeJSONError(w, r, http.StatusBadRequest, codeValidationFailed, "Error validating annotations", violations...)
+		return
+	}
+
+	var lastModified time.Time
+	if header := r.Header.Get(messageTimestampHeader); header != "" {
+		lastModified, err = parseMessageTimestamp(header)
 		if err != nil {
-			hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed validating annotations")
-			msg := fmt.Sprintf("Error validating annotations (%v)", err)
-			writeJSONError(w, msg, http.StatusBadRequest)
+			writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Invalid %s header: %s", messageTimestampHeader, header))
 			return
 		}
 	}
@@ -212,42 +811,513 @@ func (hh *httpHandler) PutAnnotations(w http.ResponseWriter, r *http.Request) {
 	if pubStr != "" {
 		publication = strings.Split(r.Header.Get(publicationHeader), ",")
 	}
-	bookmark, err := hh.annotationsService.Write(uuid, lifecycle, platformVersion, toSliceOfInterface(publication), anns)
+	put := annotationsPut{
+		uuid:            uuid,
+		lifecycle:       lifecycle,
+		platformVersion: platformVersion,
+		originSystem:    originSystem,
+		publication:     publication,
+		annotations:     anns,
+		lastModified:    lastModified,
+		ifMatch:         ifMatchVersions(r),
+	}
+	if hh.jobs != nil && preferAsync(r) {
+		hh.enqueuePut(w, r, tid, put)
+		return
+	}
+
+	bookmark, changed, err := hh.writePut(tid, put)
+	if errors.Is(err, annotations.ErrPreconditionFailed) {
+		writeJSONError(w, r, http.StatusPreconditionFailed, codePreconditionFailed, fmt.Sprintf("Annotations for content %s have changed", uuid))
+		return
+	}
+	if errors.Is(err, annotations.ErrStaleWrite) {
+		writeJSONError(w, r, http.StatusConflict, codeStaleWrite, fmt.Sprintf("Annotations for content %s have been modified after %s", uuid, r.Header.Get(messageTimestampHeader)))
+		return
+	}
 	if err != nil {
-		hh.log.WithUUID(uuid).WithTransactionID(tid).WithError(err).Error("failed writing annotations")
 		msg := fmt.Sprintf("Error creating annotations (%v)", err)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, msg)
+		return
+	}
+
+	if _, err = hh.forwardPut(tid, put, bookmark, changed); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, codeForwardingFailed, "Failed to forward message to queue")
+		return
+	}
+
+	w.Header().Add(bookmarkHeader, bookmark)
+	if !changed {
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(jsonMessage(fmt.Sprintf("Annotations for content %s unchanged", uuid)))
+		if err != nil {
+			hh.log.WithTransactionID(tid).WithUUID(uuid).WithError(err).Error("writing response")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(jsonMessage(fmt.Sprintf("Annotations for content %s created", uuid)))
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithUUID(uuid).WithError(err).Error("writing response")
+	}
+}
+
+// writePut replaces the stored annotations, the part of a PUT common to synchronous and asynchronous requests
+func (hh *httpHandler) writePut(tid string, put annotationsPut) (string, bool, error) {
+	bookmark, changed, err := hh.annotationsService.Write(put.uuid, put.lifecycle, put.platformVersion, toSliceOfInterface(put.publication), put.annotations, put.lastModified, put.ifMatch)
+	if errors.Is(err, annotations.ErrPreconditionFailed) {
+		hh.log.WithUUID(put.uuid).WithTransactionID(tid).Info("If-Match precondition failed, annotations have changed")
+		return "", false, err
+	}
+	if errors.Is(err, annotations.ErrStaleWrite) {
+		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(put.uuid).
+			Warnf("%s older than the stored ones, skipped writing in Neo4j and forwarding", hh.messageType)
+		return "", false, err
+	}
+	if err != nil {
+		hh.log.WithUUID(put.uuid).WithTransactionID(tid).WithError(err).Error("failed writing annotations")
+		msg := fmt.Sprintf("Error creating annotations (%v)", err)
+		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(put.uuid).WithError(err).Error(msg)
+		return "", false, err
+	}
+	if changed {
+		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(put.uuid).Infof("%s successfully written in Neo4j", hh.messageType)
+	} else {
+		hh.log.WithTransactionID(tid).WithUUID(put.uuid).Infof("%s unchanged, skipped writing in Neo4j", hh.messageType)
+	}
+	return bookmark, changed, nil
+}
+
+// forwardPut forwards a written PUT to the next queue when needed, reporting whether it has been forwarded
+func (hh *httpHandler) forwardPut(tid string, put annotationsPut, bookmark string, changed bool) (bool, error) {
+	if hh.forwarder == nil || (!changed && !hh.forwardUnchanged) {
+		return false, nil
+	}
+
+	hh.log.WithTransactionID(tid).WithUUID(put.uuid).Debug("Forwarding message to the next queue")
+	err := hh.forwarder.SendMessage(tid, put.originSystem, bookmark, put.platformVersion, put.uuid, put.annotations, put.publication)
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithUUID(put.uuid).WithError(err).Error("Failed to forward message to queue")
+		return false, err
+	}
+	return true, nil
+}
+
+// forwardDeletion tells the next queue that the annotations of a lifecycle have been deleted
+func (hh *httpHandler) forwardDeletion(tid string, uuid string, lifecycle string, bookmark string) error {
+	if hh.forwarder == nil {
+		return nil
+	}
+
+	hh.log.WithTransactionID(tid).WithUUID(uuid).Debug("Forwarding deletion to the next queue")
+	err := hh.forwarder.SendDeletion(tid, hh.originSystemForLifecycle(lifecycle), bookmark, hh.lifecycleMap[lifecycle], uuid)
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithUUID(uuid).WithError(err).Error("Failed to forward deletion to queue")
+	}
+	return err
+}
+
+// preferAsync tells if the client asked for the request to be processed asynchronously, as defined by RFC 7240
+func preferAsync(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enqueuePut queues a PUT for a worker to process and responds with 202 and the location of the job
+func (hh *httpHandler) enqueuePut(w http.ResponseWriter, r *http.Request, tid string, put annotationsPut) {
+	j, ok := hh.jobs.enqueue(tid, put)
+	if !ok {
+		hh.log.WithTransactionID(tid).WithUUID(put.uuid).Warn("job queue is full")
+		w.Header().Set("Retry-After", "10")
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeQueueFull, "Too many asynchronous requests are being processed")
+		return
+	}
+	hh.log.WithTransactionID(tid).WithUUID(put.uuid).Infof("queued job %s", j.ID)
+
+	w.Header().Set("Location", "/__jobs/"+j.ID)
+	w.Header().Set("Preference-Applied", "respond-async")
+	w.WriteHeader(http.StatusAccepted)
+	err := json.NewEncoder(w).Encode(j)
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithUUID(put.uuid).WithError(err).Error("writing response")
+	}
+}
+
+// processJob writes and forwards an asynchronous PUT, recording its progress
+func (hh *httpHandler) processJob(j job) {
+	bookmark, changed, err := hh.writePut(j.transaction, j.put)
+	if errors.Is(err, annotations.ErrPreconditionFailed) {
+		hh.jobs.update(j.ID, jobFailed, "", fmt.Sprintf("Annotations for content %s have changed", j.UUID), true)
+		return
+	}
+	if errors.Is(err, annotations.ErrStaleWrite) {
+		hh.jobs.update(j.ID, jobFailed, "", fmt.Sprintf("Annotations for content %s have been modified after %s", j.UUID, j.put.lastModified.Format(messageTimestampFormat)), true)
+		return
+	}
+	if err != nil {
+		hh.jobs.update(j.ID, jobFailed, "", fmt.Sprintf("Error creating annotations (%v)", err), true)
+		return
+	}
+	message := fmt.Sprintf("Annotations for content %s created", j.UUID)
+	if !changed {
+		message = fmt.Sprintf("Annotations for content %s unchanged", j.UUID)
+	}
+	hh.jobs.update(j.ID, jobWritten, bookmark, message, false)
+
+	forwarded, err := hh.forwardPut(j.transaction, j.put, bookmark, changed)
+	if err != nil {
+		hh.jobs.update(j.ID, jobFailed, "", "Failed to forward message to queue", true)
+		return
+	}
+	if forwarded {
+		hh.jobs.update(j.ID, jobForwarded, "", message, true)
+		return
+	}
+	hh.jobs.update(j.ID, jobWritten, "", message, true)
+}
+
+// GetJob reports the status of an asynchronous PUT
+func (hh *httpHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if hh.jobs == nil {
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, "Job not found")
+		return
+	}
+
+	j, ok := hh.jobs.get(mux.Vars(r)["id"])
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, codeNotFound, "Job not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(j)
+	if err != nil {
+		hh.log.WithTransactionID(transactionidutils.GetTransactionIDFromRequest(r)).WithError(err).Error("writing response")
+	}
+}
+
+// PatchAnnotations adds and removes individual annotations for a given bit of content without replacing the rest.
+// The resulting set of annotations is forwarded, so downstream consumers see the same messages as after a PUT.
+func (hh *httpHandler) PatchAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := isContentTypeJSON(r); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedContentType, err.Error())
+		return
+	}
+	vars := mux.Vars(r)
+	uuid := vars["uuid"]
+	if uuid == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "uuid required")
+		return
+	}
+
+	lifecycle := vars[lifecyclePropertyName]
+	platformVersion, ok := hh.lifecycleMap[lifecycle]
+	if !ok {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+		return
+	}
+
+	originSystem := hh.originSystemForLifecycle(lifecycle)
+	if originSystem == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "No Origin-System-Id could be deduced from the lifecycle parameter")
+		return
+	}
+
+	var patch patchRequest
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		msg := fmt.Sprintf("Error (%v) parsing annotation patch request", err)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, msg)
+		return
+	}
+	if len(patch.Add) == 0 && len(patch.Remove) == 0 {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "At least one annotation to add or remove is required")
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	violations := validateAll(hh.validator, patch.Add, "/add")
+	for idx, ref := range patch.Remove {
+		if ref.ID == "" || ref.Predicate == "" {
+			index := idx
+			violations = append(violations, validationViolation{
+				Index:   &index,
+				Pointer: fmt.Sprintf("/remove/%d", idx),
+				Message: "Annotations to remove require an id and a predicate",
+			})
+		}
+	}
+	if len(violations) > 0 {
+		hh.log.WithUUID(uuid).WithTransactionID(tid).Errorf("failed validating annotations: %d invalid", len(violations))
+		writeJSONError(w, r, http.StatusBadRequest, codeValidationFailed, "Error validating annotations", violations...)
+		return
+	}
+
+	var publication []string
+	pubStr := r.Header.Get(publicationHeader)
+	if pubStr != "" {
+		publication = strings.Split(pubStr, ",")
+	}
+	result, bookmark, err := hh.annotationsService.Patch(uuid, lifecycle, platformVersion, toSliceOfInterface(publication), patch.Add, patch.Remove, ifMatchVersions(r))
+	if errors.Is(err, annotations.ErrPreconditionFailed) {
+		hh.writePreconditionFailed(w, r, tid, uuid)
+		return
+	}
+	if errors.Is(err, annotations.ErrUnknownPredicate) {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Error patching annotations (%v)", err))
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error patching annotations (%v)", err)
 		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(uuid).WithError(err).Error(msg)
-		writeJSONError(w, msg, http.StatusServiceUnavailable)
+		writeJSONError(w, r, http.StatusServiceUnavailable, codeNeo4jError, msg)
 		return
 	}
-	hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(uuid).Infof("%s successfully written in Neo4j", hh.messageType)
+	hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(uuid).Infof("%s successfully patched in Neo4j", hh.messageType)
 
 	if hh.forwarder != nil {
 		hh.log.WithTransactionID(tid).WithUUID(uuid).Debug("Forwarding message to the next queue")
-		err = hh.forwarder.SendMessage(tid, originSystem, bookmark, platformVersion, uuid, anns, publication)
+		err = hh.forwarder.SendMessage(tid, originSystem, bookmark, platformVersion, uuid, result.Annotations, result.Publication)
 		if err != nil {
 			msg := "Failed to forward message to queue"
 			hh.log.WithTransactionID(tid).WithUUID(uuid).WithError(err).Error(msg)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err = w.Write(jsonMessage(msg))
-			if err != nil {
-				hh.log.WithTransactionID(tid).WithUUID(uuid).WithError(err).Error("writing response")
-			}
+			writeJSONError(w, r, http.StatusInternalServerError, codeForwardingFailed, msg)
 			return
 		}
 	}
 
 	w.Header().Add(bookmarkHeader, bookmark)
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(jsonMessage(fmt.Sprintf("Annotations for content %s created", uuid)))
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(result)
 	if err != nil {
 		hh.log.WithTransactionID(tid).WithUUID(uuid).WithError(err).Error("writing response")
 	}
 }
 
-func writeJSONError(w http.ResponseWriter, errorMsg string, statusCode int) {
-	w.WriteHeader(statusCode)
-	fmt.Println(w, fmt.Sprintf("{\"message\": \"%s\"}", errorMsg))
+// ValidateAnnotations runs the same checks as PutAnnotations against a payload without writing or forwarding anything.
+// Instead of stopping at the first problem, every violation found is reported.
+func (hh *httpHandler) ValidateAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var violations []validationViolation
+	addViolation := func(msg string) {
+		violations = append(violations, validationViolation{Message: msg})
+	}
+
+	if err := isContentTypeJSON(r); err != nil {
+		addViolation(err.Error())
+	}
+
+	vars := mux.Vars(r)
+	if vars["uuid"] == "" {
+		addViolation("uuid required")
+	}
+
+	lifecycle := vars[lifecyclePropertyName]
+	if _, ok := hh.lifecycleMap[lifecycle]; !ok {
+		addViolation("annotationLifecycle not supported by this application")
+	} else if hh.originSystemForLifecycle(lifecycle) == "" {
+		addViolation("No Origin-System-Id could be deduced from the lifecycle parameter")
+	}
+
+	anns, err := decode(r.Body)
+	if err != nil {
+		addViolation(fmt.Sprintf("Error (%v) parsing annotation request", err))
+	}
+	violations = append(violations, validateAll(hh.validator, anns, "")...)
+
+	result := validationResult{Valid: len(violations) == 0, Violations: violations}
+	if result.Valid {
+		result.Violations = []validationViolation{}
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		hh.log.WithTransactionID(transactionidutils.GetTransactionIDFromRequest(r)).WithError(err).Error("writing response")
+	}
+}
+
+// BulkPutAnnotations replaces the annotations of many pieces of content for a single lifecycle. Every entry is
+// validated, written and forwarded on its own, so one bad entry does not fail the others, and the response
+// reports the outcome of each entry in the order they were received.
+func (hh *httpHandler) BulkPutAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := isContentTypeJSON(r); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedContentType, err.Error())
+		return
+	}
+
+	lifecycle := mux.Vars(r)[lifecyclePropertyName]
+	platformVersion, ok := hh.lifecycleMap[lifecycle]
+	if !ok {
+		writeJSONError(w, r, http.StatusBadRequest, codeUnsupportedLifecycle, "annotationLifecycle not supported by this application")
+		return
+	}
+
+	originSystem := hh.originSystemForLifecycle(lifecycle)
+	if originSystem == "" {
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, "No Origin-System-Id could be deduced from the lifecycle parameter")
+		return
+	}
+
+	var entries []bulkEntry
+	err := json.NewDecoder(r.Body).Decode(&entries)
+	if err != nil {
+		msg := fmt.Sprintf("Error (%v) parsing bulk annotations request", err)
+		writeJSONError(w, r, http.StatusBadRequest, codeInvalidRequest, msg)
+		return
+	}
+	if len(entries) > maxBulkEntries {
+		writeJSONError(w, r, http.StatusRequestEntityTooLarge, codeTooManyEntries, fmt.Sprintf("Bulk requests are limited to %d entries", maxBulkEntries))
+		return
+	}
+
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	results := make([]bulkResult, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, hh.writeBulkEntry(tid, lifecycle, platformVersion, originSystem, entry))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(results)
+	if err != nil {
+		hh.log.WithTransactionID(tid).WithError(err).Error("writing response")
+	}
+}
+
+// checkBulkEntry validates an entry before it is written, returning false along with the result to report if it is invalid
+func (hh *httpHandler) checkBulkEntry(tid string, entry bulkEntry) (bulkResult, bool) {
+	result := bulkResult{UUID: entry.UUID}
+	if entry.UUID == "" {
+		result.Status = http.StatusBadRequest
+		result.Code = codeInvalidRequest
+		result.Message = "uuid required"
+		return result, false
+	}
+
+	if violations := validateAll(hh.validator, entry.Annotations, "/annotations"); len(violations) > 0 {
+		hh.log.WithUUID(entry.UUID).WithTransactionID(tid).Errorf("failed validating annotations: %d invalid", len(violations))
+		result.Status = http.StatusBadRequest
+		result.Code = codeValidationFailed
+		result.Message = "Error validating annotations"
+		result.Details = violations
+		return result, false
+	}
+	return result, true
+}
+
+func (hh *httpHandler) writeBulkEntry(tid, lifecycle, platformVersion, originSystem string, entry bulkEntry) bulkResult {
+	result, ok := hh.checkBulkEntry(tid, entry)
+	if !ok {
+		return result
+	}
+
+	bookmark, changed, err := hh.annotationsService.Write(entry.UUID, lifecycle, platformVersion, toSliceOfInterface(entry.Publication), entry.Annotations, time.Time{}, nil)
+	if err != nil {
+		msg := fmt.Sprintf("Error creating annotations (%v)", err)
+		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(entry.UUID).WithError(err).Error(msg)
+		result.Status = http.StatusServiceUnavailable
+		result.Code = codeNeo4jError
+		result.Message = msg
+		return result
+	}
+	if changed {
+		hh.log.WithMonitoringEvent("SaveNeo4j", tid, hh.messageType).WithUUID(entry.UUID).Infof("%s successfully written in Neo4j", hh.messageType)
+	} else {
+		hh.log.WithTransactionID(tid).WithUUID(entry.UUID).Infof("%s unchanged, skipped writing in Neo4j", hh.messageType)
+	}
+	result.Bookmark = bookmark
+
+	if hh.forwarder != nil && (changed || hh.forwardUnchanged) {
+		hh.log.WithTransactionID(tid).WithUUID(entry.UUID).Debug("Forwarding message to the next queue")
+		err = hh.forwarder.SendMessage(tid, originSystem, bookmark, platformVersion, entry.UUID, entry.Annotations, entry.Publication)
+		if err != nil {
+			hh.log.WithTransactionID(tid).WithUUID(entry.UUID).WithError(err).Error("Failed to forward message to queue")
+			result.Status = http.StatusInternalServerError
+			result.Code = codeForwardingFailed
+			result.Message = "Failed to forward message to queue"
+			return result
+		}
+	}
+
+	if !changed {
+		result.Status = http.StatusOK
+		result.Message = fmt.Sprintf("Annotations for content %s unchanged", entry.UUID)
+		return result
+	}
+	result.Status = http.StatusCreated
+	result.Message = fmt.Sprintf("Annotations for content %s created", entry.UUID)
+	return result
+}
+
+// ifMatchVersions returns the versions of the annotations listed by the If-Match header of a request, the ETags of
+// GET responses without their quotes, or nil when the request has no If-Match header. A write is only made with a
+// list of versions when the stored annotations have one of them, "*" matching any version of existing annotations.
+// Weak entity tags are left out, as they never match with the strong comparison If-Match uses.
+func ifMatchVersions(r *http.Request) []string {
+	if r.Header.Get("If-Match") == "" {
+		return nil
+	}
+
+	versions := []string{}
+	for _, header := range r.Header.Values("If-Match") {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			switch {
+			case tag == "*":
+				versions = append(versions, tag)
+			case len(tag) >= 2 && strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, `"`):
+				versions = append(versions, tag[1:len(tag)-1])
+			}
+		}
+	}
+	return versions
+}
+
+// writePreconditionFailed responds to a write whose If-Match header does not match the stored annotations.
+// The version is compared in the transaction of the write, so nothing has been written.
+func (hh *httpHandler) writePreconditionFailed(w http.ResponseWriter, r *http.Request, tid string, uuid string) {
+	hh.log.WithUUID(uuid).WithTransactionID(tid).Info("If-Match precondition failed, annotations have changed")
+	writeJSONError(w, r, http.StatusPreconditionFailed, codePreconditionFailed, fmt.Sprintf("Annotations for content %s have changed", uuid))
+}
+
+// originSystemForLifecycle returns the Origin-System-Id mapped to the given lifecycle,
+// or an empty string if there is none.
+func (hh *httpHandler) originSystemForLifecycle(lifecycle string) string {
+	for k, v := range hh.originMap {
+		if v == lifecycle {
+			return k
+		}
+	}
+	return ""
+}
+
+// validateAll validates every annotation and returns a violation for each one that fails.
+// The pointer of each violation is the index of the annotation appended to the given prefix.
+func validateAll(v jsonValidator, anns []interface{}, prefix string) []validationViolation {
+	var violations []validationViolation
+	for idx, ann := range anns {
+		err := v.Validate(ann)
+		if err != nil {
+			index := idx
+			violations = append(violations, validationViolation{
+				Index:   &index,
+				Pointer: fmt.Sprintf("%s/%d", prefix, idx),
+				Message: err.Error(),
+			})
+		}
+	}
+	return violations
 }
 
 func jsonMessage(msgText string) []byte {
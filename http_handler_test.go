@@ -7,10 +7,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Financial-Times/cm-annotations-ontology/model"
 	"github.com/Financial-Times/cm-annotations-ontology/validator"
 
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+
 	logger "github.com/Financial-Times/go-logger/v2"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -23,6 +30,7 @@ const (
 	annotationLifecycle = "annotations-pac"
 	platformVersion     = "pac"
 	bookmark            = "bookmark"
+	conceptUUID         = "2384fa7a-d514-3d6a-a0ea-3a711f66d0d8"
 )
 
 type HttpHandlerTestSuite struct {
@@ -69,121 +77,773 @@ func TestHttpHandlerTestSuite(t *testing.T) {
 }
 
 func (suite *HttpHandlerTestSuite) TestPutHandler_Success() {
-	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations).Return(bookmark, nil)
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, true, nil)
 	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
 	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
 	request.Header.Add("X-Request-Id", suite.tid)
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
 	rec := httptest.NewRecorder()
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusCreated == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusCreated))
 	assert.JSONEq(suite.T(), message("Annotations for content 12345 created"), rec.Body.String(), "Wrong body")
 	suite.forwarder.AssertExpectations(suite.T())
 }
 
+func (suite *HttpHandlerTestSuite) TestPutHandler_Stale() {
+	lastModified := time.Date(2024, 3, 1, 10, 15, 30, 0, time.UTC)
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, lastModified, []string(nil)).Return("", false, annotations.ErrStaleWrite)
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add("Message-Timestamp", "2024-03-01T10:15:30.000Z")
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusConflict, rec.Code, "Wrong response code")
+	var result errorResponse
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error")
+	assert.Equal(suite.T(), codeStaleWrite, result.Code)
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_InvalidMessageTimestamp() {
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add("Message-Timestamp", "yesterday")
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code")
+	suite.annotationsService.AssertNotCalled(suite.T(), "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_Unchanged() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, false, nil)
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), message("Annotations for content 12345 unchanged"), rec.Body.String(), "Wrong body")
+	assert.Equal(suite.T(), bookmark, rec.Header().Get(bookmarkHeader))
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_UnchangedNotForwarded() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, false, nil)
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, false, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_Async() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, true, nil)
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, newJobQueue(1, 1)}
+
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add("Prefer", "respond-async")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusAccepted == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusAccepted))
+	var accepted job
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &accepted), "Unexpected error")
+	assert.Equal(suite.T(), jobPending, accepted.Status)
+	assert.Equal(suite.T(), "/__jobs/"+accepted.ID, rec.Header().Get("Location"))
+	suite.annotationsService.AssertNotCalled(suite.T(), "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	queued, ok := handler.jobs.get(accepted.ID)
+	assert.True(suite.T(), ok, "Expected the job to be queued")
+	handler.processJob(queued)
+
+	request = newRequest("GET", rec.Header().Get("Location"), "application/json", nil)
+	rec = httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	var finished job
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &finished), "Unexpected error")
+	assert.Equal(suite.T(), jobForwarded, finished.Status)
+	assert.Equal(suite.T(), bookmark, finished.Bookmark)
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_AsyncWriteFailed() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return("", false, errors.New("Write failed"))
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, newJobQueue(1, 1)}
+
+	accepted, ok := handler.jobs.enqueue(suite.tid, annotationsPut{uuid: knownUUID, lifecycle: annotationLifecycle, platformVersion: platformVersion, annotations: suite.annotations})
+	assert.True(suite.T(), ok, "Expected the job to be queued")
+	handler.processJob(accepted)
+
+	finished, ok := handler.jobs.get(accepted.ID)
+	assert.True(suite.T(), ok, "Expected the job to be found")
+	assert.Equal(suite.T(), jobFailed, finished.Status)
+	assert.Equal(suite.T(), "Error creating annotations (Write failed)", finished.Message)
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_AsyncQueueFull() {
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, newJobQueue(1, 0)}
+	_, ok := handler.jobs.enqueue(suite.tid, annotationsPut{uuid: knownUUID})
+	assert.True(suite.T(), ok, "Expected the first job to be queued")
+
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("Prefer", "respond-async")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
+	assert.Equal(suite.T(), "10", rec.Header().Get("Retry-After"))
+}
+
+func (suite *HttpHandlerTestSuite) TestJobQueue_Expire() {
+	jobs := newJobQueue(1, 2)
+	finished, _ := jobs.enqueue(suite.tid, annotationsPut{uuid: knownUUID})
+	pending, _ := jobs.enqueue(suite.tid, annotationsPut{uuid: knownUUID})
+	jobs.update(finished.ID, jobWritten, bookmark, "", true)
+
+	jobs.expire(time.Now().UTC().Add(jobRetention / 2))
+	_, ok := jobs.get(finished.ID)
+	assert.True(suite.T(), ok, "Expected the recently finished job to be kept")
+
+	jobs.expire(time.Now().UTC().Add(2 * jobRetention))
+	_, ok = jobs.get(finished.ID)
+	assert.False(suite.T(), ok, "Expected the finished job to have expired")
+	_, ok = jobs.get(pending.ID)
+	assert.True(suite.T(), ok, "Expected the pending job to be kept")
+}
+
+func (suite *HttpHandlerTestSuite) TestJobQueue_Close() {
+	jobs := newJobQueue(2, 10)
+	var mu sync.Mutex
+	var processed []string
+	jobs.start(func(j job) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, j.ID)
+	})
+
+	var queued []string
+	for i := 0; i < 5; i++ {
+		j, ok := jobs.enqueue(suite.tid, annotationsPut{uuid: knownUUID})
+		assert.True(suite.T(), ok, "Expected the job to be queued")
+		queued = append(queued, j.ID)
+	}
+	jobs.close()
+
+	assert.Equal(suite.T(), queued, processed, "Expected the queued jobs to be processed before the queue is closed")
+	_, ok := jobs.enqueue(suite.tid, annotationsPut{uuid: knownUUID})
+	assert.False(suite.T(), ok, "Expected no job to be queued once the queue is closed")
+	jobs.close()
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_AsyncDisabled() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, true, nil)
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add("Prefer", "respond-async")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusCreated == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusCreated))
+	assert.Empty(suite.T(), rec.Header().Get("Preference-Applied"))
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestGetJobHandler_NotFound() {
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, newJobQueue(1, 1)}
+	request := newRequest("GET", "/__jobs/unknown", "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusNotFound == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNotFound))
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_IfMatch() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string{"2-7", "*"}).Return(bookmark, true, nil)
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	// weak entity tags never match
+	request.Header.Add("If-Match", `"2-7", W/"2-6"`)
+	request.Header.Add("If-Match", "*")
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusCreated == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusCreated))
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_IfMatchPreconditionFailed() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string{"stale"}).Return("", false, annotations.ErrPreconditionFailed)
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	request.Header.Add("If-Match", `"stale"`)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusPreconditionFailed == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusPreconditionFailed))
+	var result errorResponse
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error")
+	assert.Equal(suite.T(), codePreconditionFailed, result.Code)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_IfMatchAsync() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string{"*"}).Return("", false, annotations.ErrPreconditionFailed)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, newJobQueue(1, 1)}
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("Prefer", "respond-async")
+	request.Header.Add("If-Match", "*")
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusAccepted == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusAccepted))
+	var accepted job
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &accepted), "Unexpected error")
+
+	queued, ok := handler.jobs.get(accepted.ID)
+	assert.True(suite.T(), ok, "Expected the job to be queued")
+	handler.processJob(queued)
+	finished, _ := handler.jobs.get(accepted.ID)
+	assert.Equal(suite.T(), jobFailed, finished.Status)
+	assert.Equal(suite.T(), fmt.Sprintf("Annotations for content %s have changed", knownUUID), finished.Message)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
 func (suite *HttpHandlerTestSuite) TestPutHandler_ParseError() {
 	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", []byte(`{"id": "1234"}`))
 	request.Header.Add("X-Request-Id", suite.tid)
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
 	rec := httptest.NewRecorder()
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
 }
 
 func (suite *HttpHandlerTestSuite) TestPutHandler_ValidationError() {
 	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", []byte(`"{"thing": {"prefLabel": "Apple"}`))
 	request.Header.Add("X-Request-Id", suite.tid)
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
 	rec := httptest.NewRecorder()
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
 }
 
+func (suite *HttpHandlerTestSuite) TestPutHandler_ValidationErrorListsEveryAnnotation() {
+	body, err := json.Marshal([]interface{}{map[string]interface{}{"prefLabel": "Apple"}, suite.annotations[0], map[string]interface{}{"prefLabel": "Google"}})
+	assert.NoError(suite.T(), err, "Unexpected error")
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code")
+
+	var result errorResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.Equal(suite.T(), codeValidationFailed, result.Code)
+	assert.Equal(suite.T(), suite.tid, result.TransactionID)
+	if assert.Len(suite.T(), result.Details, 4) {
+		for i, pointer := range []string{"/0/id", "/0/predicate", "/2/id", "/2/predicate"} {
+			assert.Equal(suite.T(), pointer, result.Details[i].Pointer)
+			assert.Equal(suite.T(), int(pointer[1]-'0'), *result.Details[i].Index)
+		}
+	}
+	suite.annotationsService.AssertNotCalled(suite.T(), "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestPutHandler_Neo4jErrorResponse() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("", false, errors.New("neo4j is down"))
+	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, rec.Code, "Wrong response code")
+	assert.JSONEq(suite.T(), fmt.Sprintf(`{"code":"NEO4J_ERROR","message":"Error creating annotations (neo4j is down)","transactionId":"%s"}`, suite.tid), rec.Body.String(), "Wrong body")
+}
+
 func (suite *HttpHandlerTestSuite) TestPutHandler_NotJson() {
 	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "text/html", suite.body)
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
 	rec := httptest.NewRecorder()
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
 }
 
 func (suite *HttpHandlerTestSuite) TestPutHandler_WriteFailed() {
-	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations).Return("", errors.New("Write failed"))
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return("", false, errors.New("Write failed"))
 	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
 	request.Header.Add("X-Request-Id", suite.tid)
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
 	rec := httptest.NewRecorder()
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
 }
 
 func (suite *HttpHandlerTestSuite) TestPutHandler_ForwardingFailed() {
-	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations).Return(bookmark, nil)
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, true, nil)
 	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(errors.New("forwarding failed"))
 	request := newRequest("PUT", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", suite.body)
 	request.Header.Add("X-Request-Id", suite.tid)
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
 	rec := httptest.NewRecorder()
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusInternalServerError == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusInternalServerError))
 	suite.forwarder.AssertExpectations(suite.T())
 }
 
+func (suite *HttpHandlerTestSuite) TestPatchHandler_Success() {
+	remove := []annotations.AnnotationRef{{ID: "http://api.ft.com/things/ccaa202e-3d27-3b75-b2f2-261cf5038a1f", Predicate: "mentions"}}
+	result := annotations.LifecycleAnnotations{
+		Annotations: []annotations.PayloadAnnotation{{ID: "http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8", Predicate: "mentions"}},
+	}
+	suite.annotationsService.On("Patch", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations[:1], remove, []string(nil)).Return(result, bookmark, nil)
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, result.Annotations, result.Publication).Return(nil).Once()
+	body, err := json.Marshal(patchRequest{Add: suite.annotations[:1], Remove: remove})
+	assert.NoError(suite.T(), err, "Unexpected error")
+	request := newRequest("PATCH", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.Equal(suite.T(), bookmark, rec.Header().Get(bookmarkHeader))
+	assert.JSONEq(suite.T(), `{"annotations":[{"id":"http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","predicate":"mentions"}]}`, rec.Body.String(), "Wrong body")
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestPatchHandler_NoOperations() {
+	request := newRequest("PATCH", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", []byte(`{"add": [], "remove": []}`))
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+}
+
+func (suite *HttpHandlerTestSuite) TestPatchHandler_UnknownPredicate() {
+	remove := []annotations.AnnotationRef{{ID: "http://api.ft.com/things/ccaa202e-3d27-3b75-b2f2-261cf5038a1f", Predicate: "likes"}}
+	suite.annotationsService.On("Patch", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, []interface{}(nil), remove, []string(nil)).Return(annotations.LifecycleAnnotations{}, "", annotations.ErrUnknownPredicate)
+	body, err := json.Marshal(patchRequest{Remove: remove})
+	assert.NoError(suite.T(), err, "Unexpected error")
+	request := newRequest("PATCH", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", body)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
+func (suite *HttpHandlerTestSuite) TestPatchHandler_PatchFailed() {
+	suite.annotationsService.On("Patch", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, []annotations.AnnotationRef(nil), []string(nil)).Return(annotations.LifecycleAnnotations{}, "", errors.New("Patch failed"))
+	body, err := json.Marshal(patchRequest{Add: suite.annotations})
+	assert.NoError(suite.T(), err, "Unexpected error")
+	request := newRequest("PATCH", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", body)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
+func (suite *HttpHandlerTestSuite) TestValidateHandler_Valid() {
+	request := newRequest("POST", fmt.Sprintf("/content/%s/annotations/%s/__validate", knownUUID, annotationLifecycle), "application/json", suite.body)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), `{"valid": true, "violations": []}`, rec.Body.String(), "Wrong body")
+	suite.annotationsService.AssertNotCalled(suite.T(), "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
+func (suite *HttpHandlerTestSuite) TestValidateHandler_ReportsEveryViolation() {
+	body, err := json.Marshal([]interface{}{map[string]interface{}{"prefLabel": "Apple"}, suite.annotations[0], map[string]interface{}{"prefLabel": "Google"}})
+	assert.NoError(suite.T(), err, "Unexpected error")
+	request := newRequest("POST", fmt.Sprintf("/content/%s/annotations/%s/__validate", knownUUID, "annotations-invalid"), "text/html", body)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+
+	var result validationResult
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.False(suite.T(), result.Valid)
+	assert.Len(suite.T(), result.Violations, 4)
+	assert.Nil(suite.T(), result.Violations[0].Index, "Content type violation should not point at an annotation")
+	assert.Nil(suite.T(), result.Violations[1].Index, "Lifecycle violation should not point at an annotation")
+	assert.Equal(suite.T(), 0, *result.Violations[2].Index)
+	assert.Equal(suite.T(), 2, *result.Violations[3].Index)
+}
+
 func (suite *HttpHandlerTestSuite) TestGetHandler_Success() {
-	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle).Return(suite.annotations, true, nil)
+	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle, annotations.ReadFilter{}).Return(suite.annotations, "2-7", true, nil)
 	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 	expectedResponse, err := json.Marshal(suite.annotations)
 	assert.NoError(suite.T(), err, "")
 	assert.JSONEq(suite.T(), string(expectedResponse), rec.Body.String(), "Wrong body")
+	assert.Equal(suite.T(), `"2-7"`, rec.Header().Get("ETag"), "ETag should be the version of the annotations")
 }
 
 func (suite *HttpHandlerTestSuite) TestGetHandler_NotFound() {
-	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle).Return(nil, false, nil)
+	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle, annotations.ReadFilter{}).Return(nil, "", false, nil)
 	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusNotFound == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNotFound))
 }
 
 func (suite *HttpHandlerTestSuite) TestGetHandler_ReadError() {
-	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle).Return(nil, false, errors.New("Read error"))
+	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle, annotations.ReadFilter{}).Return(nil, "", false, errors.New("Read error"))
 	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
 }
 
+func (suite *HttpHandlerTestSuite) TestGetHandler_Filtered() {
+	minConfidence, minRelevance := 0.5, 0.75
+	filter := annotations.ReadFilter{Predicate: "about", Publication: "8e6c705e-1132-42a2-8db0-c295e29e8658", MinConfidence: &minConfidence, MinRelevance: &minRelevance}
+	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle, filter).Return(suite.annotations, "", true, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s?predicate=about&publication=8e6c705e-1132-42a2-8db0-c295e29e8658&minConfidence=0.5&minRelevance=0.75", knownUUID, annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	expectedResponse, err := json.Marshal(suite.annotations)
+	assert.NoError(suite.T(), err, "")
+	assert.JSONEq(suite.T(), string(expectedResponse), rec.Body.String(), "Wrong body")
+	assert.Empty(suite.T(), rec.Header().Get("ETag"), "Filtered responses should have no ETag")
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestGetHandler_FilterMatchingNothing() {
+	filter := annotations.ReadFilter{Predicate: "about"}
+	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle, filter).Return(&[]model.Annotation{}, "2-7", true, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s?predicate=about", knownUUID, annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.JSONEq(suite.T(), "[]", rec.Body.String(), "Wrong body")
+}
+
+func (suite *HttpHandlerTestSuite) TestGetHandler_InvalidFilter() {
+	suite.annotationsService.On("Read", knownUUID, mock.Anything, annotationLifecycle, annotations.ReadFilter{Predicate: "likes"}).Return(nil, "", false, annotations.ErrUnknownPredicate)
+	for _, query := range []string{"predicate=likes", "minConfidence=-0.1", "minRelevance=1.1", "minRelevance=high"} {
+		request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s?%s", knownUUID, annotationLifecycle, query), "application/json", nil)
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+		assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code for %s", query)
+	}
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Read", 1)
+}
+
 func (suite *HttpHandlerTestSuite) TestGetHandler_InvalidLifecycle() {
 	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, "annotations-invalid"), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+
+	var result errorResponse
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error")
+	assert.Equal(suite.T(), codeUnsupportedLifecycle, result.Code)
+	assert.Equal(suite.T(), "annotationLifecycle not supported by this application", result.Message)
+	assert.NotEmpty(suite.T(), result.TransactionID, "A transaction ID should be generated when the request has none")
+}
+
+func (suite *HttpHandlerTestSuite) TestGetAllHandler_Success() {
+	relevance := 0.9
+	stored := map[string]annotations.LifecycleAnnotations{
+		annotationLifecycle: {
+			Annotations: []annotations.PayloadAnnotation{{ID: "http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8", Predicate: "mentions", RelevanceScore: &relevance}},
+		},
+	}
+	suite.annotationsService.On("ReadAll", knownUUID, mock.Anything, []string{"annotations-manual", "annotations-next-video", "annotations-pac"}).Return(stored, true, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), `{"annotations-pac":{"annotations":[{"id":"http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","predicate":"mentions","relevanceScore":0.9}]}}`, rec.Body.String(), "Wrong body")
+}
+
+func (suite *HttpHandlerTestSuite) TestGetAllHandler_NotFound() {
+	suite.annotationsService.On("ReadAll", knownUUID, mock.Anything, mock.Anything).Return(map[string]annotations.LifecycleAnnotations{}, false, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusNotFound == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNotFound))
+}
+
+func (suite *HttpHandlerTestSuite) TestGetAllHandler_ReadError() {
+	suite.annotationsService.On("ReadAll", knownUUID, mock.Anything, mock.Anything).Return(map[string]annotations.LifecycleAnnotations(nil), false, errors.New("Read error"))
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
+}
+
+func (suite *HttpHandlerTestSuite) TestSummaryHandler_Success() {
+	summaries := map[string]annotations.LifecycleSummary{
+		annotationLifecycle: {
+			Count:           3,
+			Predicates:      map[string]int{"about": 1, "mentions": 2},
+			PlatformVersion: platformVersion,
+			Publication:     []string{"8e6c705e-1132-42a2-8db0-c295e29e8658"},
+			LastWritten:     "2024-03-01T10:15:30.123Z",
+		},
+	}
+	suite.annotationsService.On("Summary", knownUUID, bookmark, []string{"annotations-manual", "annotations-next-video", "annotations-pac"}).Return(summaries, true, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/__summary", knownUUID), "application/json", nil)
+	request.Header.Add(bookmarkHeader, bookmark)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.JSONEq(suite.T(), `{"annotations-pac":{"count":3,"predicates":{"about":1,"mentions":2},"platformVersion":"pac","publication":["8e6c705e-1132-42a2-8db0-c295e29e8658"],"lastWritten":"2024-03-01T10:15:30.123Z"}}`, rec.Body.String(), "Wrong body")
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.annotationsService.AssertNotCalled(suite.T(), "Read", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestSummaryHandler_NotFound() {
+	suite.annotationsService.On("Summary", knownUUID, mock.Anything, mock.Anything).Return(map[string]annotations.LifecycleSummary{}, false, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/__summary", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusNotFound, rec.Code, "Wrong response code")
+}
+
+func (suite *HttpHandlerTestSuite) TestSummaryHandler_ReadError() {
+	suite.annotationsService.On("Summary", knownUUID, mock.Anything, mock.Anything).Return(map[string]annotations.LifecycleSummary(nil), false, errors.New("Read error"))
+	request := newRequest("GET", fmt.Sprintf("/content/%s/annotations/__summary", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, rec.Code, "Wrong response code")
+}
+
+func (suite *HttpHandlerTestSuite) TestReadManyHandler_Success() {
+	relevance := 0.9
+	stored := map[string]annotations.LifecycleAnnotations{
+		knownUUID: {
+			Annotations: []annotations.PayloadAnnotation{{ID: "http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8", Predicate: "mentions", RelevanceScore: &relevance}},
+		},
+	}
+	suite.annotationsService.On("ReadMany", []string{knownUUID, "67890"}, bookmark, annotationLifecycle).Return(stored, nil)
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__read", annotationLifecycle), "application/json", []byte(`["12345","67890"]`))
+	request.Header.Add(bookmarkHeader, bookmark)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.JSONEq(suite.T(), `{"12345":{"annotations":[{"id":"http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","predicate":"mentions","relevanceScore":0.9}]}}`, rec.Body.String(), "Wrong body")
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestReadManyHandler_ReadError() {
+	suite.annotationsService.On("ReadMany", []string{knownUUID}, mock.Anything, annotationLifecycle).Return(map[string]annotations.LifecycleAnnotations(nil), errors.New("Read error"))
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__read", annotationLifecycle), "application/json", []byte(`["12345"]`))
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, rec.Code, "Wrong response code")
+}
+
+func (suite *HttpHandlerTestSuite) TestReadManyHandler_BadRequest() {
+	uuids := make([]string, maxReadManyUUIDs+1)
+	for i := range uuids {
+		uuids[i] = fmt.Sprintf("%05d", i)
+	}
+	tooMany, err := json.Marshal(uuids)
+	assert.NoError(suite.T(), err, "")
+	tests := map[string]struct {
+		lifecycle string
+		body      []byte
+		status    int
+	}{
+		"invalid lifecycle": {"annotations-invalid", []byte(`["12345"]`), http.StatusBadRequest},
+		"invalid body":      {annotationLifecycle, []byte(`{"uuids":["12345"]}`), http.StatusBadRequest},
+		"empty uuid":        {annotationLifecycle, []byte(`["12345",""]`), http.StatusBadRequest},
+		"too many uuids":    {annotationLifecycle, tooMany, http.StatusRequestEntityTooLarge},
+	}
+	for name, test := range tests {
+		request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__read", test.lifecycle), "application/json", test.body)
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+		assert.Equal(suite.T(), test.status, rec.Code, "Wrong response code for %s", name)
+	}
+	suite.annotationsService.AssertNotCalled(suite.T(), "ReadMany", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestConceptContentHandler_Success() {
+	page := annotations.ConceptContentPage{
+		Content:    []annotations.ConceptContent{{UUID: knownUUID, Predicate: "mentions", Lifecycle: annotationLifecycle}},
+		NextCursor: "next",
+	}
+	suite.annotationsService.On("ReadConceptContent", conceptUUID, mock.Anything, []string{annotationLifecycle}, "mentions", "current", 1).Return(page, nil)
+	request := newRequest("GET", fmt.Sprintf("/concepts/%s/content?lifecycle=%s&predicate=mentions&cursor=current&limit=1", conceptUUID, annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), `{"content":[{"uuid":"12345","predicate":"mentions","lifecycle":"annotations-pac"}],"nextCursor":"next"}`, rec.Body.String(), "Wrong body")
+}
+
+func (suite *HttpHandlerTestSuite) TestConceptContentHandler_DefaultsToAllLifecycles() {
+	suite.annotationsService.On("ReadConceptContent", conceptUUID, mock.Anything, []string{"annotations-manual", "annotations-next-video", "annotations-pac"}, "", "", defaultPageLimit).Return(annotations.ConceptContentPage{Content: []annotations.ConceptContent{}}, nil)
+	request := newRequest("GET", fmt.Sprintf("/concepts/%s/content", conceptUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), `{"content":[]}`, rec.Body.String(), "Wrong body")
+}
+
+func (suite *HttpHandlerTestSuite) TestConceptContentHandler_BadRequest() {
+	suite.annotationsService.On("ReadConceptContent", conceptUUID, mock.Anything, mock.Anything, mock.Anything, "invalid", mock.Anything).Return(annotations.ConceptContentPage{}, annotations.ErrInvalidCursor)
+	for _, query := range []string{"lifecycle=annotations-invalid", "limit=0", "limit=abc", "cursor=invalid"} {
+		request := newRequest("GET", fmt.Sprintf("/concepts/%s/content?%s", conceptUUID, query), "application/json", nil)
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+		assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code for %s, was %d, should be %d", query, rec.Code, http.StatusBadRequest))
+	}
+}
+
+func (suite *HttpHandlerTestSuite) TestExportHandler_Success() {
+	firstPage := make([]annotations.ContentAnnotations, exportPageSize)
+	for i := range firstPage {
+		firstPage[i] = annotations.ContentAnnotations{
+			UUID:                 fmt.Sprintf("%05d", i),
+			LifecycleAnnotations: annotations.LifecycleAnnotations{Annotations: []annotations.PayloadAnnotation{}},
+		}
+	}
+	lastPage := []annotations.ContentAnnotations{{
+		UUID: knownUUID,
+		LifecycleAnnotations: annotations.LifecycleAnnotations{
+			Publication: []string{"88fdde6c-2aa4-4f78-af02-9f680097cfd6"},
+			Annotations: []annotations.PayloadAnnotation{{ID: "http://api.ft.com/things/" + conceptUUID, Predicate: "mentions"}},
+		},
+	}}
+	suite.annotationsService.On("ReadLifecyclePage", annotationLifecycle, bookmark, "", exportPageSize).Return(firstPage, nil)
+	suite.annotationsService.On("ReadLifecyclePage", annotationLifecycle, bookmark, firstPage[exportPageSize-1].UUID, exportPageSize).Return(lastPage, nil)
+
+	request := newRequest("GET", fmt.Sprintf("/content/annotations/%s/__export", annotationLifecycle), "application/json", nil)
+	request.Header.Set(bookmarkHeader, bookmark)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.Equal(suite.T(), "application/x-ndjson", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(suite.T(), lines, exportPageSize+1, "Wrong number of records")
+	assert.JSONEq(suite.T(), `{"uuid":"00000","annotations":[]}`, lines[0])
+	assert.JSONEq(suite.T(), `{"uuid":"12345","publication":["88fdde6c-2aa4-4f78-af02-9f680097cfd6"],"annotations":[{"id":"http://api.ft.com/things/2384fa7a-d514-3d6a-a0ea-3a711f66d0d8","predicate":"mentions"}]}`, lines[exportPageSize])
+}
+
+func (suite *HttpHandlerTestSuite) TestExportHandler_ReadFailed() {
+	suite.annotationsService.On("ReadLifecyclePage", annotationLifecycle, "", "", exportPageSize).Return([]annotations.ContentAnnotations(nil), errors.New("neo4j is down"))
+
+	request := newRequest("GET", fmt.Sprintf("/content/annotations/%s/__export", annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.JSONEq(suite.T(), `{"error":"neo4j is down"}`, rec.Body.String())
+}
+
+func (suite *HttpHandlerTestSuite) TestExportHandler_InvalidLifecycle() {
+	request := newRequest("GET", "/content/annotations/annotations-invalid/__export", "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code")
+	suite.annotationsService.AssertNotCalled(suite.T(), "ReadLifecyclePage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *HttpHandlerTestSuite) TestDeleteHandler_Success() {
-	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle).Return(true, bookmark, nil)
+	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle, []string(nil)).Return(true, bookmark, nil)
+	suite.forwarder.On("SendDeletion", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID).Return(nil).Once()
 	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
+	request.Header.Add("X-Request-Id", suite.tid)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusNoContent == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNoContent))
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestDeleteHandler_ForwardingFailed() {
+	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle, []string(nil)).Return(true, bookmark, nil)
+	suite.forwarder.On("SendDeletion", mock.Anything, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID).Return(errors.New("forwarding failed"))
+	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusInternalServerError == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusInternalServerError))
+}
+
+func (suite *HttpHandlerTestSuite) TestDeleteHandler_NotFoundNotForwarded() {
+	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle, []string(nil)).Return(false, bookmark, nil)
+	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusNotFound == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNotFound))
+	suite.forwarder.AssertNotCalled(suite.T(), "SendDeletion", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestDeleteHandler_IfMatchPreconditionFailed() {
+	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle, []string{"stale"}).Return(false, "", annotations.ErrPreconditionFailed)
+	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
+	request.Header.Add("If-Match", `"stale"`)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusPreconditionFailed == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusPreconditionFailed))
+	suite.forwarder.AssertNotCalled(suite.T(), "SendDeletion", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *HttpHandlerTestSuite) TestDeleteHandler_NotFound() {
-	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle).Return(false, bookmark, nil)
+	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle, []string(nil)).Return(false, bookmark, nil)
 	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusNotFound == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNotFound))
 }
 
 func (suite *HttpHandlerTestSuite) TestDeleteHandler_DeleteError() {
-	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle).Return(false, bookmark, errors.New("Delete error"))
+	suite.annotationsService.On("Delete", knownUUID, annotationLifecycle, []string(nil)).Return(false, bookmark, errors.New("Delete error"))
 	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations/%s", knownUUID, annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
+}
+
+func (suite *HttpHandlerTestSuite) TestDeleteAllHandler_Success() {
+	lifecycles := []string{"annotations-manual", "annotations-next-video", "annotations-pac"}
+	suite.annotationsService.On("DeleteAll", knownUUID, lifecycles).Return([]string{"annotations-pac"}, bookmark, nil)
+	suite.forwarder.On("SendDeletion", mock.Anything, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID).Return(nil).Once()
+	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), `{"deleted":["annotations-pac"]}`, rec.Body.String(), "Wrong body")
+	assert.Equal(suite.T(), bookmark, rec.Header().Get(bookmarkHeader))
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestDeleteAllHandler_NotFound() {
+	suite.annotationsService.On("DeleteAll", knownUUID, mock.Anything).Return([]string{}, bookmark, nil)
+	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusNotFound == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusNotFound))
+}
+
+func (suite *HttpHandlerTestSuite) TestDeleteAllHandler_DeleteError() {
+	suite.annotationsService.On("DeleteAll", knownUUID, mock.Anything).Return([]string(nil), "", errors.New("Delete error"))
+	request := newRequest("DELETE", fmt.Sprintf("/content/%s/annotations", knownUUID), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
 }
 
@@ -191,7 +851,7 @@ func (suite *HttpHandlerTestSuite) TestCount_Success() {
 	suite.annotationsService.On("Count", annotationLifecycle, mock.Anything, platformVersion).Return(10, nil)
 	request := newRequest("GET", fmt.Sprintf("/content/annotations/%s/__count", annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	router(&httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
 }
 
@@ -199,11 +859,185 @@ func (suite *HttpHandlerTestSuite) TestCount_CountError() {
 	suite.annotationsService.On("Count", annotationLifecycle, mock.Anything, platformVersion).Return(0, errors.New("Count error"))
 	request := newRequest("GET", fmt.Sprintf("/content/annotations/%s/__count", annotationLifecycle), "application/json", nil)
 	rec := httptest.NewRecorder()
-	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log}
-	router(&handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
 	assert.True(suite.T(), http.StatusServiceUnavailable == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusServiceUnavailable))
 }
 
+func (suite *HttpHandlerTestSuite) TestCount_GroupBy() {
+	suite.annotationsService.On("CountBy", annotationLifecycle, mock.Anything, platformVersion, "predicate").Return(map[string]int{"about": 3, "mentions": 7}, nil)
+	request := newRequest("GET", fmt.Sprintf("/content/annotations/%s/__count?groupBy=predicate", annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+	assert.JSONEq(suite.T(), `{"about":3,"mentions":7}`, rec.Body.String(), "Wrong body")
+	suite.annotationsService.AssertNotCalled(suite.T(), "Count", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestCount_UnsupportedGroupBy() {
+	suite.annotationsService.On("CountBy", annotationLifecycle, mock.Anything, platformVersion, "concept").Return(map[string]int(nil), fmt.Errorf("%w: %q", annotations.ErrUnsupportedGroupBy, "concept"))
+	request := newRequest("GET", fmt.Sprintf("/content/annotations/%s/__count?groupBy=concept", annotationLifecycle), "application/json", nil)
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+}
+
+func (suite *HttpHandlerTestSuite) TestBulkPutHandler_Success() {
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, true, nil)
+	suite.annotationsService.On("Write", "67890", annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return("", false, errors.New("Write failed"))
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
+	body, err := json.Marshal([]bulkEntry{
+		{UUID: knownUUID, Annotations: suite.annotations},
+		{UUID: "67890", Annotations: suite.annotations},
+		{UUID: "13579", Annotations: []interface{}{map[string]interface{}{"prefLabel": "Apple"}}},
+	})
+	assert.NoError(suite.T(), err, "Unexpected error")
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__bulk", annotationLifecycle), "application/json", body)
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusOK == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusOK))
+
+	var results []bulkResult
+	err = json.Unmarshal(rec.Body.Bytes(), &results)
+	assert.NoError(suite.T(), err, "Unexpected error")
+	assert.Len(suite.T(), results, 3)
+	assert.Equal(suite.T(), bulkResult{UUID: knownUUID, Status: http.StatusCreated, Message: "Annotations for content 12345 created", Bookmark: bookmark}, results[0])
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, results[1].Status)
+	assert.Equal(suite.T(), http.StatusBadRequest, results[2].Status)
+	suite.annotationsService.AssertNotCalled(suite.T(), "Write", "13579", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestBulkPutHandler_InvalidLifecycle() {
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__bulk", "annotations-invalid"), "application/json", []byte(`[]`))
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+}
+
+func (suite *HttpHandlerTestSuite) TestBulkPutHandler_ParseError() {
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__bulk", annotationLifecycle), "application/json", []byte(`{"uuid": "1234"}`))
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.True(suite.T(), http.StatusBadRequest == rec.Code, fmt.Sprintf("Wrong response code, was %d, should be %d", rec.Code, http.StatusBadRequest))
+}
+
+func (suite *HttpHandlerTestSuite) TestImportHandler_Success() {
+	writes := []annotations.ContentWrite{
+		{ContentUUID: knownUUID, Lifecycle: annotationLifecycle, PlatformVersion: platformVersion, Publication: []interface{}{}, Annotations: suite.annotations},
+		{ContentUUID: "67890", Lifecycle: annotationLifecycle, PlatformVersion: platformVersion, Publication: []interface{}{}, Annotations: suite.annotations},
+	}
+	suite.annotationsService.On("WriteBatch", writes).Return(bookmark, []bool{true, false}, nil).Once()
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, knownUUID, suite.annotations, suite.publication).Return(nil).Once()
+	suite.forwarder.On("SendMessage", suite.tid, "http://cmdb.ft.com/systems/pac", bookmark, platformVersion, "67890", suite.annotations, suite.publication).Return(nil).Once()
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	assert.NoError(suite.T(), enc.Encode(bulkEntry{UUID: knownUUID, Annotations: suite.annotations}))
+	body.WriteString("not json\n\n")
+	assert.NoError(suite.T(), enc.Encode(bulkEntry{UUID: "13579", Annotations: []interface{}{map[string]interface{}{"prefLabel": "Apple"}}}))
+	assert.NoError(suite.T(), enc.Encode(bulkEntry{UUID: "67890", Annotations: suite.annotations}))
+
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__import?forward=true", annotationLifecycle), "application/x-ndjson", body.Bytes())
+	request.Header.Add("X-Request-Id", suite.tid)
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	var results []importResult
+	dec := json.NewDecoder(rec.Body)
+	for dec.More() {
+		var result importResult
+		assert.NoError(suite.T(), dec.Decode(&result), "Unexpected error")
+		results = append(results, result)
+	}
+	// the invalid records are reported before the batch holding the valid ones has been written
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+	assert.Len(suite.T(), results, 4)
+	assert.Equal(suite.T(), importResult{Line: 1, bulkResult: bulkResult{UUID: knownUUID, Status: http.StatusCreated, Message: "Annotations for content 12345 created", Bookmark: bookmark}}, results[0])
+	assert.Equal(suite.T(), 2, results[1].Line)
+	assert.Equal(suite.T(), http.StatusBadRequest, results[1].Status)
+	assert.Equal(suite.T(), importResult{Line: 4, bulkResult: bulkResult{UUID: "13579", Status: http.StatusBadRequest, Code: codeValidationFailed, Message: "Error validating annotations", Details: results[2].Details}}, results[2])
+	if assert.Len(suite.T(), results[2].Details, 1) {
+		assert.Equal(suite.T(), "/annotations/0", results[2].Details[0].Pointer)
+	}
+	assert.Equal(suite.T(), importResult{Line: 5, bulkResult: bulkResult{UUID: "67890", Status: http.StatusOK, Message: "Annotations for content 67890 unchanged", Bookmark: bookmark}}, results[3])
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.forwarder.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestImportHandler_WritesInBatches() {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for i := 0; i <= importBatchSize; i++ {
+		assert.NoError(suite.T(), enc.Encode(bulkEntry{UUID: fmt.Sprintf("%05d", i), Annotations: suite.annotations}))
+	}
+	suite.annotationsService.On("WriteBatch", mock.MatchedBy(func(writes []annotations.ContentWrite) bool { return len(writes) == importBatchSize })).Return(bookmark, make([]bool, importBatchSize), nil).Once()
+	suite.annotationsService.On("WriteBatch", mock.MatchedBy(func(writes []annotations.ContentWrite) bool { return len(writes) == 1 })).Return("", nil, errors.New("Write failed")).Once()
+	suite.annotationsService.On("Write", fmt.Sprintf("%05d", importBatchSize), annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return("", false, errors.New("Write failed")).Once()
+
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__import", annotationLifecycle), "application/x-ndjson", body.Bytes())
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(suite.T(), lines, importBatchSize+1)
+	var last importResult
+	assert.NoError(suite.T(), json.Unmarshal([]byte(lines[importBatchSize]), &last))
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, last.Status)
+	suite.annotationsService.AssertExpectations(suite.T())
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *HttpHandlerTestSuite) TestImportHandler_FailedBatchWritesRecordsOneByOne() {
+	suite.annotationsService.On("WriteBatch", mock.Anything).Return("", nil, errors.New("Write failed")).Once()
+	suite.annotationsService.On("Write", knownUUID, annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return(bookmark, true, nil).Once()
+	suite.annotationsService.On("Write", "67890", annotationLifecycle, platformVersion, []interface{}{}, suite.annotations, time.Time{}, []string(nil)).Return("", false, errors.New("Write failed")).Once()
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	assert.NoError(suite.T(), enc.Encode(bulkEntry{UUID: knownUUID, Annotations: suite.annotations}))
+	assert.NoError(suite.T(), enc.Encode(bulkEntry{UUID: "67890", Annotations: suite.annotations}))
+
+	request := newRequest("POST", fmt.Sprintf("/content/annotations/%s/__import", annotationLifecycle), "application/x-ndjson", body.Bytes())
+	handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if assert.Len(suite.T(), lines, 2) {
+		var written, failed importResult
+		assert.NoError(suite.T(), json.Unmarshal([]byte(lines[0]), &written))
+		assert.NoError(suite.T(), json.Unmarshal([]byte(lines[1]), &failed))
+		assert.Equal(suite.T(), http.StatusCreated, written.Status)
+		assert.Equal(suite.T(), bookmark, written.Bookmark)
+		assert.Equal(suite.T(), http.StatusServiceUnavailable, failed.Status)
+		assert.Equal(suite.T(), codeNeo4jError, failed.Code)
+	}
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *HttpHandlerTestSuite) TestImportHandler_BadRequest() {
+	for _, url := range []string{
+		"/content/annotations/annotations-invalid/__import",
+		fmt.Sprintf("/content/annotations/%s/__import?forward=maybe", annotationLifecycle),
+	} {
+		request := newRequest("POST", url, "application/x-ndjson", nil)
+		handler := httpHandler{suite.validator, suite.annotationsService, suite.forwarder, suite.originMap, suite.lifecycleMap, suite.messageType, suite.log, true, nil}
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), &handler, &suite.healthCheckHandler, suite.log).ServeHTTP(rec, request)
+		assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code for %s", url)
+	}
+}
+
 func newRequest(method, url, contentType string, body []byte) *http.Request {
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
 	if err != nil {
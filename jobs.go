@@ -0,0 +1,199 @@
+package main
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of an asynchronous PUT
+const (
+	jobPending   = "pending"
+	jobWritten   = "written"
+	jobForwarded = "forwarded"
+	jobFailed    = "failed"
+)
+
+const (
+	jobQueueSize = 1000
+	jobWorkers   = 4
+	// jobRetention is how long finished jobs can be looked up for
+	jobRetention = time.Hour
+	// jobSweepInterval is how often the finished jobs are checked for expiry
+	jobSweepInterval = time.Minute
+)
+
+// annotationsPut holds everything needed to replace the annotations a lifecycle has written for a piece of content
+type annotationsPut struct {
+	uuid            string
+	lifecycle       string
+	platformVersion string
+	originSystem    string
+	publication     []string
+	annotations     []interface{}
+	// lastModified is when the annotations were modified, the zero time skipping the check for stale writes
+	lastModified time.Time
+	// ifMatch lists the versions of the annotations given by the If-Match header, nil without one
+	ifMatch []string
+}
+
+// job is an asynchronous PUT, as reported by the job status endpoint
+type job struct {
+	ID          string    `json:"id"`
+	UUID        string    `json:"uuid"`
+	Lifecycle   string    `json:"annotationLifecycle"`
+	Status      string    `json:"status"`
+	Bookmark    string    `json:"bookmark,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	transaction string
+	put         annotationsPut
+	done        bool
+}
+
+// jobQueue is an in-process queue of asynchronous PUTs. Jobs for the same content always go to the same worker,
+// so they are written in the order they have been accepted. Jobs are lost when the service restarts.
+type jobQueue struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	queues []chan string
+	// closed rejects the jobs enqueued once the queue has been closed
+	closed bool
+	// sweeper ticks when the finished jobs are checked for expiry, until stopSweeping is closed
+	sweeper      *time.Ticker
+	stopSweeping chan struct{}
+	workers      sync.WaitGroup
+}
+
+func newJobQueue(workers int, size int) *jobQueue {
+	q := &jobQueue{jobs: map[string]*job{}}
+	for i := 0; i < workers; i++ {
+		q.queues = append(q.queues, make(chan string, size/workers+1))
+	}
+	return q
+}
+
+// start runs a goroutine per worker handing the queued jobs to process, and one forgetting the expired jobs,
+// until the queue is closed
+func (q *jobQueue) start(process func(j job)) {
+	q.sweeper = time.NewTicker(jobSweepInterval)
+	q.stopSweeping = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-q.sweeper.C:
+				q.expire(now.UTC())
+			case <-q.stopSweeping:
+				return
+			}
+		}
+	}()
+
+	for _, queue := range q.queues {
+		q.workers.Add(1)
+		go func(queue chan string) {
+			defer q.workers.Done()
+			for id := range queue {
+				j, ok := q.get(id)
+				if ok {
+					process(j)
+				}
+			}
+		}(queue)
+	}
+}
+
+// close stops accepting jobs and waits for the workers to process the jobs already queued
+func (q *jobQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	for _, queue := range q.queues {
+		close(queue)
+	}
+	q.mu.Unlock()
+
+	if q.sweeper != nil {
+		q.sweeper.Stop()
+		close(q.stopSweeping)
+	}
+	q.workers.Wait()
+}
+
+// enqueue accepts an asynchronous PUT, returning false if the queue of its worker is full or the queue is closed
+func (q *jobQueue) enqueue(tid string, put annotationsPut) (job, bool) {
+	now := time.Now().UTC()
+	j := &job{
+		ID:          uuid.New().String(),
+		UUID:        put.uuid,
+		Lifecycle:   put.lifecycle,
+		Status:      jobPending,
+		Created:     now,
+		Updated:     now,
+		transaction: tid,
+		put:         put,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return job{}, false
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(put.uuid))
+	select {
+	case q.queues[h.Sum32()%uint32(len(q.queues))] <- j.ID:
+		q.jobs[j.ID] = j
+		return *j, true
+	default:
+		return job{}, false
+	}
+}
+
+func (q *jobQueue) get(id string) (job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// update records the progress of a job, done being true once nothing else will happen to it
+func (q *jobQueue) update(id string, status string, bookmark string, message string, done bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return
+	}
+	j.Status = status
+	if bookmark != "" {
+		j.Bookmark = bookmark
+	}
+	j.Message = message
+	j.Updated = time.Now().UTC()
+	if done {
+		j.done = true
+		j.put = annotationsPut{}
+	}
+}
+
+// expire forgets the jobs that finished more than jobRetention ago
+func (q *jobQueue) expire(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, j := range q.jobs {
+		if j.done && now.Sub(j.Updated) > jobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Financial-Times/cm-annotations-ontology/validator"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/Financial-Times/http-handlers-go/v2/httphandlers"
 	status "github.com/Financial-Times/service-status-go/httphandlers"
 
+	"github.com/Shopify/sarama"
 	"github.com/gorilla/mux"
 	cli "github.com/jawher/mow.cli"
 	metrics "github.com/rcrowley/go-metrics"
@@ -91,12 +93,77 @@ func main() {
 		Desc:   "Topic to which received messages will be forwarded",
 		EnvVar: "PRODUCER_TOPIC",
 	})
+	deadLetterTopic := app.String(cli.StringOpt{
+		Name:   "deadLetterTopic",
+		Desc:   "Topic to which the consumed messages that cannot be processed are sent, along with the reason. Leave empty to drop them",
+		EnvVar: "DEAD_LETTER_TOPIC",
+	})
+	writeRetries := app.Int(cli.IntOpt{
+		Name:   "writeRetries",
+		Value:  5,
+		Desc:   "Number of times a consumed message is written again when Neo4j fails with a transient error, such as during a leader switch",
+		EnvVar: "WRITE_RETRIES",
+	})
+	writeRetryInitialBackoff := app.Int(cli.IntOpt{
+		Name:   "writeRetryInitialBackoff",
+		Value:  200,
+		Desc:   "Milliseconds to wait before the first retry of a write, doubled with every retry",
+		EnvVar: "WRITE_RETRY_INITIAL_BACKOFF",
+	})
+	writeRetryMaxBackoff := app.Int(cli.IntOpt{
+		Name:   "writeRetryMaxBackoff",
+		Value:  5000,
+		Desc:   "Maximum milliseconds to wait before a retry of a write",
+		EnvVar: "WRITE_RETRY_MAX_BACKOFF",
+	})
+	consumerWorkers := app.Int(cli.IntOpt{
+		Name:   "consumerWorkers",
+		Value:  32,
+		Desc:   "Number of consumed messages processed in parallel. Messages for the same content are always processed in order",
+		EnvVar: "CONSUMER_WORKERS",
+	})
+	consumerMaxInFlight := app.Int(cli.IntOpt{
+		Name:   "consumerMaxInFlight",
+		Value:  100,
+		Desc:   "Maximum number of consumed messages of a partition being processed at the same time, whose offsets are not committed yet",
+		EnvVar: "CONSUMER_MAX_IN_FLIGHT",
+	})
+	writeBatchSize := app.Int(cli.IntOpt{
+		Name:   "writeBatchSize",
+		Value:  1,
+		Desc:   "Maximum number of consumed messages written to Neo4j in a single transaction. 1 writes every message in its own transaction",
+		EnvVar: "WRITE_BATCH_SIZE",
+	})
+	writeBatchWait := app.Int(cli.IntOpt{
+		Name:   "writeBatchWait",
+		Value:  50,
+		Desc:   "Maximum milliseconds a consumed message waits for a batch to fill up before being written",
+		EnvVar: "WRITE_BATCH_WAIT",
+	})
 	shouldForwardMessages := app.Bool(cli.BoolOpt{
 		Name:   "shouldForwardMessages",
 		Value:  true,
 		Desc:   "Decides if annotations messages should be forwarded to a post publication queue",
 		EnvVar: "SHOULD_FORWARD_MESSAGES",
 	})
+	forwardUnchanged := app.Bool(cli.BoolOpt{
+		Name:   "forwardUnchanged",
+		Value:  true,
+		Desc:   "Decides if annotations messages that leave the stored annotations unchanged should still be forwarded to a post publication queue",
+		EnvVar: "FORWARD_UNCHANGED",
+	})
+	asyncPuts := app.Bool(cli.BoolOpt{
+		Name:   "asyncPuts",
+		Value:  false,
+		Desc:   "Enables the asynchronous PUTs requested with the Prefer: respond-async header, which are queued in memory",
+		EnvVar: "ASYNC_PUTS",
+	})
+	forwardStale := app.Bool(cli.BoolOpt{
+		Name:   "forwardStale",
+		Value:  false,
+		Desc:   "Decides if annotations messages older than the stored annotations, which are not written, should still be forwarded to a post publication queue",
+		EnvVar: "FORWARD_STALE",
+	})
 	appName := app.String(cli.StringOpt{
 		Name:   "appName",
 		Value:  "annotations-rw",
@@ -155,11 +222,22 @@ func main() {
 			lifecycleMap:       lifecycleMap,
 			messageType:        messageType,
 			log:                log,
+			forwardUnchanged:   *forwardUnchanged,
+		}
+		if *asyncPuts {
+			hh.jobs = newJobQueue(jobWorkers, jobQueueSize)
+			hh.jobs.start(hh.processJob)
 		}
 
 		var qh queueHandler
 		if *shouldConsumeMessages {
-			consumer := setupMessageConsumer(*kafkaAddress, *consumerGroup, *consumerTopics, int64(*kafkaLagTolerance), log)
+			if *consumerWorkers < 1 {
+				log.Fatal("consumerWorkers must be at least 1")
+			}
+			if *consumerMaxInFlight < 1 {
+				log.Fatal("consumerMaxInFlight must be at least 1")
+			}
+			consumer := setupMessageConsumer(*kafkaAddress, *consumerGroup, *consumerTopics, int64(*kafkaLagTolerance), *consumerMaxInFlight, log)
 
 			healtcheckHandler.consumer = consumer
 
@@ -172,7 +250,17 @@ func main() {
 				lifecycleMap:       lifecycleMap,
 				messageType:        messageType,
 				log:                log,
+				forwardUnchanged:   *forwardUnchanged,
+				forwardStale:       *forwardStale,
+				retry:              newRetryPolicy(*writeRetries, time.Duration(*writeRetryInitialBackoff)*time.Millisecond, time.Duration(*writeRetryMaxBackoff)*time.Millisecond, annotations.IsTransient),
+			}
+			if *deadLetterTopic != "" {
+				qh.deadLetters = newDeadLetterQueue(setupMessageProducer(*kafkaAddress, *deadLetterTopic, log))
 			}
+			if *writeBatchSize > 1 {
+				qh.batches = newWriteBatcher(*writeBatchSize, time.Duration(*writeBatchWait)*time.Millisecond, qh.writeBatch)
+			}
+			qh.workers = newContentWorkers(*consumerWorkers, *consumerMaxInFlight)
 
 			qh.Ingest()
 		}
@@ -191,8 +279,68 @@ func main() {
 			log.Infof("Shutting down Kafka consumer")
 			qh.consumer.Close()
 		}
+		if hh.jobs != nil {
+			log.Infof("Waiting for the queued asynchronous PUTs")
+			hh.jobs.close()
+		}
 	}
 
+	app.Command("replay-dead-letters", "Replays the messages of the dead-letter topic through the normal pipeline, once the cause of their failure is fixed", func(cmd *cli.Cmd) {
+		replayConsumerGroup := cmd.String(cli.StringOpt{
+			Name:   "replayConsumerGroup",
+			Value:  "annotations-rw-dead-letter-replay",
+			Desc:   "Kafka consumer group reading the dead-letter topic, which starts from the oldest message when new",
+			EnvVar: "REPLAY_CONSUMER_GROUP",
+		})
+		idleTimeout := cmd.Int(cli.IntOpt{
+			Name:   "idleTimeout",
+			Value:  60,
+			Desc:   "Seconds without a dead-lettered message after which the replay stops",
+			EnvVar: "REPLAY_IDLE_TIMEOUT",
+		})
+
+		cmd.Action = func() {
+			log := logger.NewUPPLogger(*appName, *logLevel, logger.KeyNamesConfig{KeyTime: "@time"})
+			if *deadLetterTopic == "" {
+				log.Fatal("deadLetterTopic is required to replay dead-lettered messages")
+			}
+
+			dbLog := logger.NewUPPLogger(*appName+"-cmneo4j-driver", *dbDriverLogLevel)
+			annotationsService, err := setupAnnotationsService(*neoURL, *publicAPIHost, dbLog)
+			if err != nil {
+				log.WithError(err).Fatal("can't initialise annotations service")
+			}
+			originMap, lifecycleMap, messageType, err := readConfigMap(*config)
+			if err != nil {
+				log.WithError(err).Fatal("can't read service configuration")
+			}
+
+			var f forwarder.QueueForwarder
+			if *shouldForwardMessages {
+				f = &forwarder.Forwarder{
+					Producer:    setupMessageProducer(*kafkaAddress, *producerTopic, log),
+					MessageType: messageType,
+				}
+			}
+
+			qh := queueHandler{
+				validator:          validator.NewSchemaValidator(log).GetJSONValidator(),
+				annotationsService: annotationsService,
+				consumer:           setupDeadLetterConsumer(*kafkaAddress, *replayConsumerGroup, *deadLetterTopic, log),
+				forwarder:          f,
+				originMap:          originMap,
+				lifecycleMap:       lifecycleMap,
+				messageType:        messageType,
+				log:                log,
+				forwardUnchanged:   *forwardUnchanged,
+				forwardStale:       *forwardStale,
+				deadLetters:        newDeadLetterQueue(setupMessageProducer(*kafkaAddress, *deadLetterTopic, log)),
+				retry:              newRetryPolicy(*writeRetries, time.Duration(*writeRetryInitialBackoff)*time.Millisecond, time.Duration(*writeRetryMaxBackoff)*time.Millisecond, annotations.IsTransient),
+			}
+			replayDeadLetters(&qh, time.Duration(*idleTimeout)*time.Second, log)
+		}
+	})
+
 	err := app.Run(os.Args)
 	if err != nil {
 		fmt.Printf("app could not start: %s", err)
@@ -232,19 +380,27 @@ func setupMessageProducer(brokerAddress string, producerTopic string, log *logge
 	return producer
 }
 
-func setupMessageConsumer(kafkaAddress string, consumerGroup string, topics []string, lagTolerance int64, log *logger.UPPLogger) *kafka.Consumer {
+func setupMessageConsumer(kafkaAddress string, consumerGroup string, topics []string, lagTolerance int64, maxInFlight int, log *logger.UPPLogger) *orderedConsumer {
 	consumerConfig := kafka.ConsumerConfig{
 		BrokersConnectionString: kafkaAddress,
 		ConsumerGroup:           consumerGroup,
 		Options:                 kafka.DefaultConsumerOptions(),
 	}
 
-	var kafkaTopics []*kafka.Topic
-	for _, topic := range topics {
-		kafkaTopics = append(kafkaTopics, kafka.NewTopic(topic, kafka.WithLagTolerance(lagTolerance)))
+	return newOrderedConsumer(consumerConfig, topics, lagTolerance, maxInFlight, log)
+}
+
+// setupDeadLetterConsumer reads the dead-letter topic from its oldest message the first time the consumer group is used
+func setupDeadLetterConsumer(kafkaAddress string, consumerGroup string, topic string, log *logger.UPPLogger) *orderedConsumer {
+	options := kafka.DefaultConsumerOptions()
+	options.Consumer.Offsets.Initial = sarama.OffsetOldest
+	consumerConfig := kafka.ConsumerConfig{
+		BrokersConnectionString: kafkaAddress,
+		ConsumerGroup:           consumerGroup,
+		Options:                 options,
 	}
 
-	return kafka.NewConsumer(consumerConfig, kafkaTopics, log)
+	return newOrderedConsumer(consumerConfig, []string{topic}, 0, 1, log)
 }
 
 func readConfigMap(jsonPath string) (originMap map[string]string, lifecycleMap map[string]string, messageType string, err error) {
@@ -273,14 +429,37 @@ func readConfigMap(jsonPath string) (originMap map[string]string, lifecycleMap m
 }
 
 func router(hh *httpHandler, hc *healthCheckHandler, log *logger.UPPLogger) http.Handler {
+	var monitoringRouter http.Handler = validateRequests(apiSpecification, routes(hh, hc))
+	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log, monitoringRouter)
+	monitoringRouter = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, monitoringRouter)
+
+	return monitoringRouter
+}
+
+// routes registers every endpoint of the service, each of which has to be described in api/api.yml
+func routes(hh *httpHandler, hc *healthCheckHandler) *mux.Router {
 	servicesRouter := mux.NewRouter()
 	servicesRouter.Headers("Content-type: application/json")
 
 	// Then API specific ones:
+	servicesRouter.HandleFunc("/content/{uuid}/annotations", hh.GetAllAnnotations).Methods("GET")
+	servicesRouter.HandleFunc("/content/{uuid}/annotations", hh.DeleteAllAnnotations).Methods("DELETE")
+	// registered before the lifecycle routes, which would otherwise match __summary as a lifecycle
+	servicesRouter.HandleFunc("/content/{uuid}/annotations/__summary", hh.GetAnnotationsSummary).Methods("GET")
 	servicesRouter.HandleFunc("/content/{uuid}/annotations/{annotationLifecycle}", hh.GetAnnotations).Methods("GET")
 	servicesRouter.HandleFunc("/content/{uuid}/annotations/{annotationLifecycle}", hh.PutAnnotations).Methods("PUT")
+	servicesRouter.HandleFunc("/content/{uuid}/annotations/{annotationLifecycle}", hh.PatchAnnotations).Methods("PATCH")
 	servicesRouter.HandleFunc("/content/{uuid}/annotations/{annotationLifecycle}", hh.DeleteAnnotations).Methods("DELETE")
+	servicesRouter.HandleFunc("/content/{uuid}/annotations/{annotationLifecycle}/__validate", hh.ValidateAnnotations).Methods("POST")
 	servicesRouter.HandleFunc("/content/annotations/{annotationLifecycle}/__count", hh.CountAnnotations).Methods("GET")
+	servicesRouter.HandleFunc("/content/annotations/{annotationLifecycle}/__export", hh.ExportAnnotations).Methods("GET")
+	servicesRouter.HandleFunc("/content/annotations/{annotationLifecycle}/__import", hh.ImportAnnotations).Methods("POST")
+	servicesRouter.HandleFunc("/concepts/{conceptUUID}/content", hh.GetConceptContent).Methods("GET")
+	servicesRouter.HandleFunc("/content/annotations/{annotationLifecycle}/__bulk", hh.BulkPutAnnotations).Methods("POST")
+	servicesRouter.HandleFunc("/content/annotations/{annotationLifecycle}/__read", hh.ReadManyAnnotations).Methods("POST")
+	servicesRouter.HandleFunc("/__jobs/{id}", hh.GetJob).Methods("GET")
+	servicesRouter.HandleFunc("/__admin/concepts/{oldUUID}/remap/{newUUID}", hh.RemapConcept).Methods("POST")
+	servicesRouter.HandleFunc("/__admin/concepts/{conceptUUID}/retire", hh.RetireConcept).Methods("POST")
 
 	servicesRouter.HandleFunc("/__health", hc.Health()).Methods("GET")
 	servicesRouter.HandleFunc("/__gtg", status.NewGoodToGoHandler(hc.GTG)).Methods("GET")
@@ -288,12 +467,10 @@ func router(hh *httpHandler, hc *healthCheckHandler, log *logger.UPPLogger) http
 	servicesRouter.HandleFunc(status.PingPathDW, status.PingHandler).Methods("GET")
 	servicesRouter.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler).Methods("GET")
 	servicesRouter.HandleFunc(status.BuildInfoPathDW, status.BuildInfoHandler).Methods("GET")
+	servicesRouter.HandleFunc("/__api", GetAPI).Methods("GET")
+	servicesRouter.HandleFunc("/__metrics", GetMetrics).Methods("GET")
 
-	var monitoringRouter http.Handler = servicesRouter
-	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log, monitoringRouter)
-	monitoringRouter = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, monitoringRouter)
-
-	return monitoringRouter
+	return servicesRouter
 }
 
 func startServer(port int) error {
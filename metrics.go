@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Counters of the Neo4j writes of the queue handler, which retries the writes failing with a transient error
+var (
+	// writeRetries counts every retry
+	writeRetries = metrics.GetOrRegisterCounter("consumer.neo4j.write.retries", metrics.DefaultRegistry)
+	// writeRetriesRecovered counts the messages written after at least one retry
+	writeRetriesRecovered = metrics.GetOrRegisterCounter("consumer.neo4j.write.recovered", metrics.DefaultRegistry)
+	// writeRetriesExhausted counts the messages still failing with a transient error once every retry has been made
+	writeRetriesExhausted = metrics.GetOrRegisterCounter("consumer.neo4j.write.exhausted", metrics.DefaultRegistry)
+	// writePermanentFailures counts the messages failing with an error that retrying would not fix
+	writePermanentFailures = metrics.GetOrRegisterCounter("consumer.neo4j.write.permanent_failures", metrics.DefaultRegistry)
+	// writeStale counts the messages skipped because they are older than the stored annotations
+	writeStale = metrics.GetOrRegisterCounter("consumer.neo4j.write.stale", metrics.DefaultRegistry)
+)
+
+// Counters of the batches of messages the queue handler writes in a single transaction
+var (
+	// batchWrites counts the batches written in a single transaction
+	batchWrites = metrics.GetOrRegisterCounter("consumer.neo4j.batch.writes", metrics.DefaultRegistry)
+	// batchedMessages counts the messages written as part of a batch
+	batchedMessages = metrics.GetOrRegisterCounter("consumer.neo4j.batch.messages", metrics.DefaultRegistry)
+	// batchFallbacks counts the batches which failed and have been written one message at a time
+	batchFallbacks = metrics.GetOrRegisterCounter("consumer.neo4j.batch.fallbacks", metrics.DefaultRegistry)
+)
+
+// GetMetrics serves the metrics of the service, those of the HTTP requests included, keyed by name
+func GetMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(metrics.DefaultRegistry.GetAll())
+}
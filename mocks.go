@@ -2,9 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/Financial-Times/kafka-client-go/v3"
 
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -17,22 +20,72 @@ func (mf *mockForwarder) SendMessage(transactionID string, originSystem string,
 	return args.Error(0)
 }
 
+func (mf *mockForwarder) SendDeletion(transactionID string, originSystem string, bookmark string, platformVersion string, uuid string) error {
+	args := mf.Called(transactionID, originSystem, bookmark, platformVersion, uuid)
+	return args.Error(0)
+}
+
 type mockAnnotationsService struct {
 	mock.Mock
 }
 
-func (as *mockAnnotationsService) Write(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, thing interface{}) (bookmark string, err error) {
-	args := as.Called(contentUUID, annotationLifecycle, platformVersion, publication, thing)
-	return args.String(0), args.Error(1)
+func (as *mockAnnotationsService) Write(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, thing interface{}, lastModified time.Time, ifMatch []string) (bookmark string, changed bool, err error) {
+	args := as.Called(contentUUID, annotationLifecycle, platformVersion, publication, thing, lastModified, ifMatch)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+func (as *mockAnnotationsService) WriteBatch(writes []annotations.ContentWrite) (bookmark string, changed []bool, err error) {
+	args := as.Called(writes)
+	changed, _ = args.Get(1).([]bool)
+	return args.String(0), changed, args.Error(2)
+}
+func (as *mockAnnotationsService) Read(contentUUID string, bookmark string, annotationLifecycle string, filter annotations.ReadFilter) (thing interface{}, version string, found bool, err error) {
+	args := as.Called(contentUUID, bookmark, annotationLifecycle, filter)
+	return args.Get(0), args.String(1), args.Bool(2), args.Error(3)
+}
+func (as *mockAnnotationsService) ReadAll(contentUUID string, bookmark string, annotationLifecycles []string) (map[string]annotations.LifecycleAnnotations, bool, error) {
+	args := as.Called(contentUUID, bookmark, annotationLifecycles)
+	return args.Get(0).(map[string]annotations.LifecycleAnnotations), args.Bool(1), args.Error(2)
+}
+func (as *mockAnnotationsService) ReadMany(contentUUIDs []string, bookmark string, annotationLifecycle string) (map[string]annotations.LifecycleAnnotations, error) {
+	args := as.Called(contentUUIDs, bookmark, annotationLifecycle)
+	return args.Get(0).(map[string]annotations.LifecycleAnnotations), args.Error(1)
+}
+func (as *mockAnnotationsService) Summary(contentUUID string, bookmark string, annotationLifecycles []string) (map[string]annotations.LifecycleSummary, bool, error) {
+	args := as.Called(contentUUID, bookmark, annotationLifecycles)
+	return args.Get(0).(map[string]annotations.LifecycleSummary), args.Bool(1), args.Error(2)
+}
+func (as *mockAnnotationsService) Patch(contentUUID string, annotationLifecycle string, platformVersion string, publication []interface{}, add []interface{}, remove []annotations.AnnotationRef, ifMatch []string) (annotations.LifecycleAnnotations, string, error) {
+	args := as.Called(contentUUID, annotationLifecycle, platformVersion, publication, add, remove, ifMatch)
+	return args.Get(0).(annotations.LifecycleAnnotations), args.String(1), args.Error(2)
+}
+func (as *mockAnnotationsService) ReadLifecyclePage(annotationLifecycle string, bookmark string, afterUUID string, limit int) ([]annotations.ContentAnnotations, error) {
+	args := as.Called(annotationLifecycle, bookmark, afterUUID, limit)
+	return args.Get(0).([]annotations.ContentAnnotations), args.Error(1)
+}
+func (as *mockAnnotationsService) RemapConcept(oldConceptUUID string, newConceptUUID string, annotationLifecycles []string, limit int, auditID string) (annotations.ConceptBatch, error) {
+	args := as.Called(oldConceptUUID, newConceptUUID, annotationLifecycles, limit, auditID)
+	return args.Get(0).(annotations.ConceptBatch), args.Error(1)
 }
-func (as *mockAnnotationsService) Read(contentUUID string, bookmark string, annotationLifecycle string) (thing interface{}, found bool, err error) {
-	args := as.Called(contentUUID, bookmark, annotationLifecycle)
-	return args.Get(0), args.Bool(1), args.Error(2)
+func (as *mockAnnotationsService) RetireConcept(conceptUUID string, annotationLifecycles []string, limit int, auditID string) (annotations.ConceptBatch, error) {
+	args := as.Called(conceptUUID, annotationLifecycles, limit, auditID)
+	return args.Get(0).(annotations.ConceptBatch), args.Error(1)
 }
-func (as *mockAnnotationsService) Delete(contentUUID string, annotationLifecycle string) (found bool, bookmark string, err error) {
-	args := as.Called(contentUUID, annotationLifecycle)
+func (as *mockAnnotationsService) WriteConceptAudit(audit annotations.ConceptAudit) error {
+	args := as.Called(audit)
+	return args.Error(0)
+}
+func (as *mockAnnotationsService) ReadConceptContent(conceptUUID string, bookmark string, annotationLifecycles []string, predicate string, cursor string, limit int) (annotations.ConceptContentPage, error) {
+	args := as.Called(conceptUUID, bookmark, annotationLifecycles, predicate, cursor, limit)
+	return args.Get(0).(annotations.ConceptContentPage), args.Error(1)
+}
+func (as *mockAnnotationsService) Delete(contentUUID string, annotationLifecycle string, ifMatch []string) (found bool, bookmark string, err error) {
+	args := as.Called(contentUUID, annotationLifecycle, ifMatch)
 	return args.Bool(0), args.String(1), args.Error(2)
 }
+func (as *mockAnnotationsService) DeleteAll(contentUUID string, annotationLifecycles []string) (deleted []string, bookmark string, err error) {
+	args := as.Called(contentUUID, annotationLifecycles)
+	return args.Get(0).([]string), args.String(1), args.Error(2)
+}
 func (as *mockAnnotationsService) Check() (err error) {
 	args := as.Called()
 	return args.Error(0)
@@ -45,11 +98,24 @@ func (as *mockAnnotationsService) Count(annotationLifecycle string, bookmark str
 	args := as.Called(annotationLifecycle, bookmark, platformVersion)
 	return args.Int(0), args.Error(1)
 }
+func (as *mockAnnotationsService) CountBy(annotationLifecycle string, bookmark string, platformVersion string, groupBy string) (map[string]int, error) {
+	args := as.Called(annotationLifecycle, bookmark, platformVersion, groupBy)
+	return args.Get(0).(map[string]int), args.Error(1)
+}
 func (as *mockAnnotationsService) Initialise() error {
 	args := as.Called()
 	return args.Error(0)
 }
 
+type mockProducer struct {
+	mock.Mock
+}
+
+func (mp *mockProducer) SendMessage(message kafka.FTMessage) error {
+	args := mp.Called(message)
+	return args.Error(0)
+}
+
 type mockConsumer struct {
 	message kafka.FTMessage
 	err     error
@@ -0,0 +1,254 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// apiDocument is the OpenAPI specification of the service, served at /__api
+//
+//go:embed api/api.yml
+var apiDocument []byte
+
+// validatedByHandler is the extension marking the request bodies which are left to their handler to validate,
+// such as the streamed newline-delimited JSON of an import, which is validated one line at a time
+const validatedByHandler = "x-validated-by-handler"
+
+// annotationSchemas are the schemas of the annotations the handlers validate themselves, reporting a body whose
+// annotations are invalid with VALIDATION_FAILED rather than INVALID_REQUEST
+var annotationSchemas = []string{"Annotation", "AnnotationRef"}
+
+// apiSpec is an OpenAPI 3 document along with the router finding the operation describing a request
+type apiSpec struct {
+	document    *openapi3.T
+	router      routers.Router
+	annotations map[*openapi3.Schema]bool
+}
+
+// apiSpecification is the parsed apiDocument. The document is part of the binary, so failing to parse it is a bug.
+var apiSpecification = func() *apiSpec {
+	spec, err := parseAPISpec(apiDocument)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}()
+
+// parseAPISpec reads and validates an OpenAPI document, resolving its references
+func parseAPISpec(document []byte) (*apiSpec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("parsing API specification: %w", err)
+	}
+	if err = doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("validating API specification: %w", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("routing API specification: %w", err)
+	}
+	annotations := make(map[*openapi3.Schema]bool, len(annotationSchemas))
+	for _, name := range annotationSchemas {
+		if schema := doc.Components.Schemas[name]; schema != nil {
+			annotations[schema.Value] = true
+		}
+	}
+	return &apiSpec{document: doc, router: router, annotations: annotations}, nil
+}
+
+// find returns the route describing a request along with the values of its path parameters,
+// or nil if the specification does not describe the request
+func (s *apiSpec) find(r *http.Request) (*routers.Route, map[string]string) {
+	route, vars, err := s.router.FindRoute(r)
+	if err != nil {
+		return nil, nil
+	}
+	return route, vars
+}
+
+// validateRequest checks the parameters, the content type and the body of a request against its route,
+// returning the code of the error response along with a violation for each problem found. The codes are those the
+// handlers return for the same problems: UNSUPPORTED_CONTENT_TYPE, VALIDATION_FAILED when the only problems are
+// annotations which do not match their schema, or INVALID_REQUEST for anything the handlers could not read.
+func (s *apiSpec) validateRequest(r *http.Request, route *routers.Route, vars map[string]string) (string, []validationViolation) {
+	op := route.Operation
+	excludeBody := false
+	var bodySchema *openapi3.Schema
+	if body := op.RequestBody; body != nil && body.Value != nil {
+		mediaType := body.Value.Content.Get(r.Header.Get("Content-Type"))
+		if mediaType == nil {
+			return codeUnsupportedContentType, []validationViolation{{Message: fmt.Sprintf("Content-Type %q is not supported", r.Header.Get("Content-Type"))}}
+		}
+		_, excludeBody = body.Value.Extensions[validatedByHandler]
+		if mediaType.Schema != nil {
+			bodySchema = mediaType.Schema.Value
+		}
+	}
+
+	// a repeated parameter would otherwise be read as its first value
+	var violations []validationViolation
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		p := param.Value
+		if p.In == openapi3.ParameterInQuery && len(query[p.Name]) > 1 && (p.Schema == nil || p.Schema.Value.Type != openapi3.TypeArray) {
+			violations = append(violations, validationViolation{Message: fmt.Sprintf("query %s must not be repeated", p.Name)})
+		}
+	}
+
+	err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: vars,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			ExcludeRequestBody: excludeBody,
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	if err == nil {
+		return codeInvalidRequest, violations
+	}
+
+	code := codeValidationFailed
+	if len(violations) > 0 {
+		code = codeInvalidRequest
+	}
+	errs, ok := err.(openapi3.MultiError)
+	if !ok {
+		errs = openapi3.MultiError{err}
+	}
+	for _, err := range errs {
+		if !s.invalidAnnotation(err, bodySchema) {
+			code = codeInvalidRequest
+		}
+		violations = append(violations, specViolations(err, "")...)
+	}
+	return code, violations
+}
+
+// invalidAnnotation tells if an error found validating a request is about an annotation of its body,
+// which the handlers would have read and then failed to validate
+func (s *apiSpec) invalidAnnotation(err error, bodySchema *openapi3.Schema) bool {
+	requestErr, ok := err.(*openapi3filter.RequestError)
+	if !ok || requestErr.RequestBody == nil {
+		return false
+	}
+	schemaErrs := []error{requestErr.Err}
+	if multi, ok := requestErr.Err.(openapi3.MultiError); ok {
+		schemaErrs = multi
+	}
+	for _, err := range schemaErrs {
+		var schemaErr *openapi3.SchemaError
+		if !errors.As(err, &schemaErr) || !s.withinAnnotation(bodySchema, schemaErrorPointer(schemaErr)) {
+			return false
+		}
+	}
+	return true
+}
+
+// schemaErrorPointer returns the pointer to the value at fault, following the errors of the schemas combined
+// with allOf, anyOf or oneOf down to the one which failed
+func schemaErrorPointer(err *openapi3.SchemaError) []string {
+	pointer := err.JSONPointer()
+	var origin *openapi3.SchemaError
+	if errors.As(err.Origin, &origin) {
+		pointer = append(pointer, schemaErrorPointer(origin)...)
+	}
+	return pointer
+}
+
+// withinAnnotation tells if the value at the given pointer into a body of the given schema is,
+// or is part of, an annotation
+func (s *apiSpec) withinAnnotation(schema *openapi3.Schema, pointer []string) bool {
+	if schema == nil {
+		return false
+	}
+	if s.annotations[schema] {
+		return true
+	}
+	for _, sub := range schema.AllOf {
+		if s.withinAnnotation(sub.Value, pointer) {
+			return true
+		}
+	}
+	if len(pointer) == 0 {
+		return false
+	}
+	next := schema.Properties[pointer[0]]
+	if schema.Items != nil {
+		next = schema.Items
+	}
+	return next != nil && s.withinAnnotation(next.Value, pointer[1:])
+}
+
+// specViolations turns the errors found validating a request into violations, pointing at the part of the body at fault
+func specViolations(err error, subject string) []validationViolation {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var violations []validationViolation
+		for _, err := range e {
+			violations = append(violations, specViolations(err, subject)...)
+		}
+		return violations
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			subject = fmt.Sprintf("%s %s", e.Parameter.In, e.Parameter.Name)
+		case e.RequestBody != nil:
+			subject = "request body"
+		}
+		if errors.Is(e.Err, openapi3filter.ErrInvalidRequired) {
+			return []validationViolation{{Message: subject + " is required"}}
+		}
+		if e.Err == nil {
+			return []validationViolation{{Message: fmt.Sprintf("%s %s", subject, e.Reason)}}
+		}
+		return specViolations(e.Err, subject)
+	case *openapi3.SchemaError:
+		violation := validationViolation{Message: fmt.Sprintf("%s %s", subject, e.Reason)}
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			violation.Pointer = "/" + strings.Join(pointer, "/")
+			// as in the violations of the handlers, the index of the annotation at fault in a list
+			if index, err := strconv.Atoi(pointer[0]); err == nil {
+				violation.Index = &index
+			}
+		}
+		return []validationViolation{violation}
+	}
+	return []validationViolation{{Message: fmt.Sprintf("%s %v", subject, err)}}
+}
+
+// validateRequests rejects the requests that do not match the API specification with 400, before they reach next.
+// Requests the specification does not describe are passed on untouched, for the router to respond with 404 or 405.
+func validateRequests(spec *apiSpec, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, vars := spec.find(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if code, violations := spec.validateRequest(r, route, vars); len(violations) > 0 {
+			writeJSONError(w, r, http.StatusBadRequest, code, "Request does not match the API specification", violations...)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetAPI serves the OpenAPI specification of the service
+func GetAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(apiDocument)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// streamedMediaType is the media type of the responses written as they are produced, whose bodies are not validated
+const streamedMediaType = "application/x-ndjson"
+
+// validateResponse checks a response against its route: its status code has to be described,
+// and its body has to match the schema of its content type unless it has been streamed
+func validateResponse(r *http.Request, route *routers.Route, vars map[string]string, status int, header http.Header, body []byte) error {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: r, PathParams: vars, Route: route},
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{
+			ExcludeResponseBody:   mediaType == streamedMediaType,
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	})
+}
+
+// validateResponses fails the test when a response of next does not match the API specification
+func validateResponses(t assert.TestingT, spec *apiSpec, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, vars := spec.find(r)
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+
+		if route != nil {
+			err := validateResponse(r, route, vars, rec.Code, rec.Header(), rec.Body.Bytes())
+			assert.NoError(t, err, "Invalid response to %s %s", r.Method, r.URL.Path)
+		}
+		for name, values := range rec.Header() {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	})
+}
+
+// specRouter is the router of the service, failing the test when a response does not match the API specification
+func specRouter(t *testing.T, hh *httpHandler, hc *healthCheckHandler, log *logger.UPPLogger) http.Handler {
+	return validateResponses(t, apiSpecification, router(hh, hc, log))
+}
+
+type OpenAPITestSuite struct {
+	suite.Suite
+	annotationsService *mockAnnotationsService
+	log                *logger.UPPLogger
+}
+
+func (suite *OpenAPITestSuite) SetupTest() {
+	suite.annotationsService = new(mockAnnotationsService)
+	suite.log = logger.NewUPPInfoLogger("annotations-rw")
+}
+
+func TestOpenAPITestSuite(t *testing.T) {
+	suite.Run(t, new(OpenAPITestSuite))
+}
+
+func (suite *OpenAPITestSuite) serve(request *http.Request) *httptest.ResponseRecorder {
+	hh := &httpHandler{annotationsService: suite.annotationsService, lifecycleMap: map[string]string{"annotations-pac": "pac"}, log: suite.log}
+	rec := httptest.NewRecorder()
+	specRouter(suite.T(), hh, &healthCheckHandler{}, suite.log).ServeHTTP(rec, request)
+	return rec
+}
+
+func (suite *OpenAPITestSuite) TestSpecDescribesEveryRoute() {
+	err := routes(&httpHandler{}, &healthCheckHandler{}).Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			// not an endpoint, such as the header matcher registered on the router itself
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+
+		item, ok := apiSpecification.document.Paths[template]
+		if !assert.True(suite.T(), ok, "%s is not described in the API specification", template) {
+			return nil
+		}
+		for _, method := range methods {
+			assert.NotNil(suite.T(), item.GetOperation(method), "%s %s is not described in the API specification", method, template)
+		}
+		return nil
+	})
+	assert.NoError(suite.T(), err, "Unexpected error")
+}
+
+func (suite *OpenAPITestSuite) TestGetAPI() {
+	rec := suite.serve(newRequest("GET", "/__api", "", nil))
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.Equal(suite.T(), "application/yaml", rec.Header().Get("Content-Type"))
+	assert.Equal(suite.T(), apiDocument, rec.Body.Bytes())
+}
+
+func (suite *OpenAPITestSuite) TestRequestsNotMatchingTheSpecAreRejected() {
+	tests := map[string]*http.Request{
+		"integer query parameter":  newRequest("GET", "/concepts/1234/content?limit=ten", "", nil),
+		"integer out of range":     newRequest("GET", "/concepts/1234/content?limit=1001", "", nil),
+		"number query parameter":   newRequest("GET", "/content/1234/annotations/annotations-pac?minConfidence=high", "", nil),
+		"number out of range":      newRequest("GET", "/content/1234/annotations/annotations-pac?minRelevance=1.5", "", nil),
+		"enum query parameter":     newRequest("GET", "/content/annotations/annotations-pac/__count?groupBy=concept", "", nil),
+		"boolean query parameter":  newRequest("POST", "/content/annotations/annotations-pac/__import?forward=yes", "application/x-ndjson", nil),
+		"repeated query parameter": newRequest("GET", "/concepts/1234/content?limit=1&limit=2", "", nil),
+		"required query parameter": newRequest("POST", "/__admin/concepts/1234/retire", "", nil),
+		"required request body":    newRequest("PUT", "/content/1234/annotations/annotations-pac", "application/json", nil),
+		"request body not JSON":    newRequest("POST", "/content/annotations/annotations-pac/__read", "application/json", []byte(`[1234`)),
+		"request body not a list":  newRequest("PUT", "/content/1234/annotations/annotations-pac", "application/json", []byte(`{"id":"http://www.ft.com/thing/1234"}`)),
+		"request body item type":   newRequest("POST", "/content/annotations/annotations-pac/__read", "application/json", []byte(`[1234]`)),
+	}
+	for name, request := range tests {
+		rec := suite.serve(request)
+		assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code for %s", name)
+
+		var result errorResponse
+		assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error for %s", name)
+		assert.Equal(suite.T(), codeInvalidRequest, result.Code, "Wrong code for %s", name)
+		assert.Len(suite.T(), result.Details, 1, "Wrong details for %s", name)
+	}
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *OpenAPITestSuite) TestAnnotationsNotMatchingTheSpecAreRejected() {
+	tests := map[string]*http.Request{
+		"required annotation property": newRequest("PATCH", "/content/1234/annotations/annotations-pac", "application/json", []byte(`{"remove":[{"id":"http://www.ft.com/thing/1234"}]}`)),
+		"annotation property type":     newRequest("PATCH", "/content/1234/annotations/annotations-pac", "application/json", []byte(`{"add":[{"id":"http://www.ft.com/thing/1234","predicate":"about","relevanceScore":"high"}]}`)),
+	}
+	for name, request := range tests {
+		rec := suite.serve(request)
+		assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code for %s", name)
+
+		var result errorResponse
+		assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error for %s", name)
+		assert.Equal(suite.T(), codeValidationFailed, result.Code, "Wrong code for %s", name)
+		assert.Len(suite.T(), result.Details, 1, "Wrong details for %s: %v", name, result.Details)
+	}
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *OpenAPITestSuite) TestRequestsNotMatchingTheSpecGetTheErrorsOfTheHandlers() {
+	tests := map[string]func() *http.Request{
+		"integer out of range": func() *http.Request {
+			return newRequest("GET", "/concepts/1234/content?limit=1001", "", nil)
+		},
+		"number query parameter": func() *http.Request {
+			return newRequest("GET", "/content/1234/annotations/annotations-pac?minConfidence=high", "", nil)
+		},
+		"boolean query parameter": func() *http.Request {
+			return newRequest("POST", "/content/annotations/annotations-pac/__import?forward=yes", "application/x-ndjson", nil)
+		},
+		"required query parameter": func() *http.Request {
+			return newRequest("POST", "/__admin/concepts/1234/retire", "", nil)
+		},
+		"unsupported content type": func() *http.Request {
+			return newRequest("POST", "/content/annotations/annotations-pac/__bulk", "text/plain", []byte("[]"))
+		},
+		"required request body": func() *http.Request {
+			return newRequest("PUT", "/content/1234/annotations/annotations-pac", "application/json", nil)
+		},
+		"request body not JSON": func() *http.Request {
+			return newRequest("POST", "/content/annotations/annotations-pac/__read", "application/json", []byte(`[1234`))
+		},
+		"request body not a list": func() *http.Request {
+			return newRequest("PUT", "/content/1234/annotations/annotations-pac", "application/json", []byte(`{"id":"http://www.ft.com/thing/1234"}`))
+		},
+		"request body item type": func() *http.Request {
+			return newRequest("POST", "/content/annotations/annotations-pac/__read", "application/json", []byte(`[1234]`))
+		},
+		"required annotation property": func() *http.Request {
+			return newRequest("PATCH", "/content/1234/annotations/annotations-pac", "application/json", []byte(`{"remove":[{"id":"http://www.ft.com/thing/1234"}]}`))
+		},
+	}
+	hh := &httpHandler{annotationsService: suite.annotationsService, originMap: map[string]string{"http://cmdb.ft.com/systems/pac": "annotations-pac"}, lifecycleMap: map[string]string{"annotations-pac": "pac"}, log: suite.log}
+	for name, request := range tests {
+		// the same request handled without being validated against the specification
+		handled := httptest.NewRecorder()
+		routes(hh, &healthCheckHandler{}).ServeHTTP(handled, request())
+		var expected errorResponse
+		assert.NoError(suite.T(), json.Unmarshal(handled.Body.Bytes(), &expected), "Unexpected error for %s", name)
+
+		rec := httptest.NewRecorder()
+		specRouter(suite.T(), hh, &healthCheckHandler{}, suite.log).ServeHTTP(rec, request())
+		assert.Equal(suite.T(), handled.Code, rec.Code, "Wrong response code for %s", name)
+		var result errorResponse
+		assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error for %s", name)
+		assert.Equal(suite.T(), expected.Code, result.Code, "Wrong code for %s", name)
+	}
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *OpenAPITestSuite) TestInvalidRequestBodiesPointAtTheProblem() {
+	body := `[{"id":"http://www.ft.com/thing/1234","predicate":"about"},{"id":"http://www.ft.com/thing/5678","relevanceScore":"high"}]`
+	rec := suite.serve(newRequest("PUT", "/content/1234/annotations/annotations-pac", "application/json", []byte(body)))
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code")
+
+	var result errorResponse
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error")
+	assert.Equal(suite.T(), codeValidationFailed, result.Code, "Wrong code")
+	pointers := make([]string, 0, len(result.Details))
+	for _, violation := range result.Details {
+		pointers = append(pointers, violation.Pointer)
+	}
+	assert.ElementsMatch(suite.T(), []string{"/1/predicate", "/1/relevanceScore"}, pointers, "Wrong details %v", result.Details)
+	suite.annotationsService.AssertExpectations(suite.T())
+}
+
+func (suite *OpenAPITestSuite) TestUnsupportedContentTypesAreRejected() {
+	rec := suite.serve(newRequest("POST", "/content/annotations/annotations-pac/__bulk", "text/plain", []byte("[]")))
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code")
+
+	var result errorResponse
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error")
+	assert.Equal(suite.T(), codeUnsupportedContentType, result.Code, "Wrong code")
+	assert.Len(suite.T(), result.Details, 1, "Wrong details")
+}
+
+func (suite *OpenAPITestSuite) TestBodiesValidatedByTheHandlerArePassedOn() {
+	rec := suite.serve(newRequest("POST", "/content/1234/annotations/annotations-pac/__validate", "text/plain", []byte(`{"id":1}`)))
+	assert.Equal(suite.T(), http.StatusBadRequest, rec.Code, "Wrong response code")
+
+	var result validationResult
+	assert.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &result), "Unexpected error")
+	assert.False(suite.T(), result.Valid, "The handler should have reported the violations")
+	assert.Len(suite.T(), result.Violations, 3, "Wrong violations %v", result.Violations)
+}
+
+func (suite *OpenAPITestSuite) TestRequestsMatchingTheSpecArePassedOn() {
+	suite.annotationsService.On("CountBy", "annotations-pac", "", "pac", "predicate").Return(map[string]int{"about": 1}, nil)
+	rec := suite.serve(newRequest("GET", "/content/annotations/annotations-pac/__count?groupBy=predicate", "", nil))
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "Wrong response code")
+	assert.JSONEq(suite.T(), `{"about":1}`, rec.Body.String(), "Wrong body")
+}
+
+func (suite *OpenAPITestSuite) TestRequestsNotInTheSpecAreLeftToTheRouter() {
+	rec := suite.serve(newRequest("GET", "/content/1234", "", nil))
+	assert.Equal(suite.T(), http.StatusNotFound, rec.Code, "Wrong response code")
+	rec = suite.serve(newRequest("POST", "/content/1234/annotations", "", nil))
+	assert.Equal(suite.T(), http.StatusMethodNotAllowed, rec.Code, "Wrong response code")
+}
+
+func (suite *OpenAPITestSuite) TestValidateResponse() {
+	request := newRequest("PUT", "/content/1234/annotations/annotations-pac", "application/json", nil)
+	route, vars := apiSpecification.find(request)
+	if !assert.NotNil(suite.T(), route, "PUT is not described in the API specification") {
+		return
+	}
+	assert.Equal(suite.T(), map[string]string{"uuid": "1234", "annotationLifecycle": "annotations-pac"}, vars)
+
+	jsonHeader := http.Header{"Content-Type": []string{"application/json; charset=UTF-8"}}
+	assert.NoError(suite.T(), validateResponse(request, route, vars, http.StatusCreated, jsonHeader, []byte(`{"message":"Annotations for content 1234 created"}`)))
+	assert.NoError(suite.T(), validateResponse(request, route, vars, http.StatusServiceUnavailable, jsonHeader, []byte(`{"code":"NEO4J_ERROR","message":"Error writing annotations","transactionId":"tid_1234"}`)))
+	assert.Error(suite.T(), validateResponse(request, route, vars, http.StatusCreated, http.Header{"Content-Type": []string{"text/plain"}}, []byte("created")))
+	assert.Error(suite.T(), validateResponse(request, route, vars, http.StatusServiceUnavailable, jsonHeader, []byte(`{"message":"Error writing annotations"}`)))
+	assert.Error(suite.T(), validateResponse(request, route, vars, http.StatusNotFound, jsonHeader, []byte(`{}`)))
+}
+
+// failures records the failures of a test
+type failures []string
+
+func (f *failures) Errorf(format string, args ...interface{}) {
+	*f = append(*f, fmt.Sprintf(format, args...))
+}
+
+func (suite *OpenAPITestSuite) TestInvalidResponsesFailTheTest() {
+	var failed failures
+	handler := validateResponses(&failed, apiSpecification, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"about":"one"}`))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("GET", "/content/annotations/annotations-pac/__count?groupBy=predicate", "", nil))
+	assert.Equal(suite.T(), http.StatusOK, rec.Code, "The response should be left as it is")
+	assert.JSONEq(suite.T(), `{"about":"one"}`, rec.Body.String(), "The response should be left as it is")
+	assert.Len(suite.T(), failed, 1, "The response should have failed the test")
+}
+
+func (suite *OpenAPITestSuite) TestParseAPISpecRejectsUnknownReferences() {
+	document := `
+paths:
+  /things:
+    get:
+      parameters:
+        - $ref: '#/components/parameters/Missing'
+`
+	_, err := parseAPISpec([]byte(document))
+	assert.Error(suite.T(), err, fmt.Sprintf("Expected an error parsing %s", document))
+}
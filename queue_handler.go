@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Financial-Times/kafka-client-go/v3"
 
@@ -11,8 +13,6 @@ import (
 
 	logger "github.com/Financial-Times/go-logger/v2"
 	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
-
-	"github.com/pkg/errors"
 )
 
 const (
@@ -22,6 +22,13 @@ const (
 	annotationsMsgKey = "annotations"
 	uuidMsgKey        = "uuid"
 	publicationMsgKey = "publication"
+	lastModifiedKey   = "lastModified"
+)
+
+const (
+	messageTimestampHeader = "Message-Timestamp"
+	// messageTimestampFormat is the format of the Message-Timestamp header set by the forwarder
+	messageTimestampFormat = "2006-01-02T15:04:05.000Z0700"
 )
 
 type kafkaConsumer interface {
@@ -31,6 +38,13 @@ type kafkaConsumer interface {
 	ConnectivityCheck() error
 }
 
+// asyncConsumer is a kafkaConsumer which can hand messages over without waiting for them to be processed,
+// committing the offset of a message once done has been called for it and for every message before it in its partition
+type asyncConsumer interface {
+	kafkaConsumer
+	StartAsync(func(message kafka.FTMessage, done func()))
+}
+
 type jsonValidator interface {
 	Validate(interface{}) error
 }
@@ -44,110 +58,331 @@ type queueHandler struct {
 	lifecycleMap       map[string]string
 	messageType        string
 	log                *logger.UPPLogger
+	// forwardUnchanged decides if messages leaving the stored annotations as they are should still be forwarded
+	forwardUnchanged bool
+	// forwardStale decides if messages older than the stored annotations, which are not written, should still be forwarded
+	forwardStale bool
+	// deadLetters receives the messages which cannot be processed, nil drops them
+	deadLetters *deadLetterQueue
+	// retry decides how the writes failing with a transient error are retried
+	retry retryPolicy
+	// workers processes the messages handed over by an asyncConsumer in parallel while keeping the order of those for
+	// the same content, nil processes them on the goroutines of the consumer
+	workers *contentWorkers
+	// batches writes the annotations of the messages processed at the same time in a single transaction,
+	// nil writes every message in its own transaction
+	batches *writeBatcher
+}
+
+// consumedMessage is a consumed message along with its body, decoded once as it is consumed
+type consumedMessage struct {
+	kafka.FTMessage
+	body map[string]interface{}
+	// err tells why the body could not be decoded
+	err error
+}
+
+func decodeMessage(message kafka.FTMessage) consumedMessage {
+	consumed := consumedMessage{FTMessage: message}
+	consumed.err = json.Unmarshal([]byte(message.Body), &consumed.body)
+	return consumed
+}
+
+// contentUUID returns the UUID of the content of the message, or an empty string if it has none,
+// which the queue handler rejects anyway
+func (m consumedMessage) contentUUID() string {
+	contentUUID, _ := m.body[uuidMsgKey].(string)
+	return contentUUID
 }
 
 func (qh *queueHandler) Ingest() {
-	qh.consumer.Start(func(message kafka.FTMessage) {
-		tid, found := message.Headers[transactionidutils.TransactionIDHeader]
-		if !found {
-			qh.log.Error("Missing transaction id from message")
-			return
-		}
+	if consumer, ok := qh.consumer.(asyncConsumer); ok && qh.workers != nil {
+		consumer.StartAsync(qh.dispatch)
+		return
+	}
+	qh.consumer.Start(qh.handleMessage)
+}
 
-		originSystem, found := message.Headers["Origin-System-Id"]
-		if !found {
-			qh.log.Error("Missing Origin-System-Id header from message")
-			return
+// dispatch hands a message over to the worker of its content, which calls done once the message has been processed
+func (qh *queueHandler) dispatch(message kafka.FTMessage, done func()) {
+	consumed := decodeMessage(message)
+	qh.workers.run(consumed.contentUUID(), func() {
+		qh.process(consumed, done)
+	})
+}
+
+// handleMessage processes a message on the goroutine of the consumer, waiting for it to be processed
+func (qh *queueHandler) handleMessage(message kafka.FTMessage) {
+	processed := make(chan struct{})
+	qh.process(decodeMessage(message), func() { close(processed) })
+	<-processed
+}
+
+// process writes the annotations of a message and forwards them to the next queue, calling done once the message
+// has been processed, which happens after process returns when the write is batched. The messages which
+// cannot be processed are sent to the dead-letter topic, if there is one, rather than being lost.
+func (qh *queueHandler) process(consumed consumedMessage, done func()) {
+	handedOver := false
+	defer func() {
+		if !handedOver {
+			done()
 		}
+	}()
 
-		// Ignoring Video messages from NativeCmsMetadataPublicationEvents topic as the corresponding ones produced from
-		// the upp-next-video-annotations-mapper would be ingested from the ConceptAnnotations topic
-		if originSystem == nextVideoOrigin && message.Headers["Message-Type"] == cmsMessageType {
-			qh.log.WithField("Message-Type", cmsMessageType).WithField("Origin-System-Id", nextVideoOrigin).Info("Ignoring message")
+	message := consumed.FTMessage
+	tid, found := message.Headers[transactionidutils.TransactionIDHeader]
+	if !found {
+		qh.log.Error("Missing transaction id from message")
+		qh.sendToDeadLetter(message, stageHeaders, errors.New("missing X-Request-Id header"))
+		return
+	}
+
+	originSystem, found := message.Headers["Origin-System-Id"]
+	if !found {
+		qh.log.Error("Missing Origin-System-Id header from message")
+		qh.sendToDeadLetter(message, stageHeaders, errors.New("missing Origin-System-Id header"))
+		return
+	}
+
+	// Ignoring Video messages from NativeCmsMetadataPublicationEvents topic as the corresponding ones produced from
+	// the upp-next-video-annotations-mapper would be ingested from the ConceptAnnotations topic
+	if originSystem == nextVideoOrigin && message.Headers["Message-Type"] == cmsMessageType {
+		qh.log.WithField("Message-Type", cmsMessageType).WithField("Origin-System-Id", nextVideoOrigin).Info("Ignoring message")
+		return
+	}
+
+	lifecycle, platformVersion, err := qh.getSourceFromHeader(originSystem)
+	if err != nil {
+		qh.log.WithError(err).Error("Could not get source from header")
+		return
+	}
+
+	annMsg := consumed.body
+	if consumed.err != nil {
+		qh.log.WithTransactionID(tid).Error("Cannot process received message", tid)
+		qh.sendToDeadLetter(message, stageUnmarshal, consumed.err)
+		return
+	}
+
+	contentUUID, ok := annMsg[uuidMsgKey].(string)
+	if !ok {
+		qh.log.WithTransactionID(tid).Error("Missing content uuid from message")
+		qh.sendToDeadLetter(message, stageValidation, errors.New("missing content uuid"))
+		return
+	}
+
+	var publication []interface{}
+	pubSlice, ok := annMsg[publicationMsgKey]
+	if ok {
+		publication, ok = pubSlice.([]interface{})
+		if !ok {
+			qh.log.Error("Publication field format is not supported")
+			qh.sendToDeadLetter(message, stageValidation, errors.New("publication field format is not supported"))
 			return
 		}
+	}
 
-		lifecycle, platformVersion, err := qh.getSourceFromHeader(originSystem)
-		if err != nil {
-			qh.log.WithError(err).Error("Could not get source from header")
+	annotationsKey := annotationsMsgKey
+	if qh.messageType != "Annotations" {
+		annotationsKey = suggestionsMsgKey
+	}
+	err = qh.validate(annotationsKey, annMsg[annotationsKey])
+	if err != nil {
+		qh.log.WithError(err).Error("Validation error")
+		qh.sendToDeadLetter(message, stageValidation, err)
+		return
+	}
+
+	write := annotations.ContentWrite{
+		ContentUUID:     contentUUID,
+		Lifecycle:       lifecycle,
+		PlatformVersion: platformVersion,
+		Publication:     publication,
+		Annotations:     annMsg[annotationsKey],
+		LastModified:    qh.lastModified(message, annMsg),
+	}
+	if qh.batches != nil {
+		handedOver = true
+		qh.batches.add(tid, write, func(bookmark string, changed bool, err error) {
+			defer done()
+			qh.written(message, tid, originSystem, write, annMsg[annotationsMsgKey], bookmark, changed, err)
+		})
+		return
+	}
+	bookmark, changed, err := qh.writeOne(tid, write)
+	qh.written(message, tid, originSystem, write, annMsg[annotationsMsgKey], bookmark, changed, err)
+}
+
+// written logs the outcome of the write of the annotations of a message, then forwards them to the next queue
+func (qh *queueHandler) written(message kafka.FTMessage, tid string, originSystem string, write annotations.ContentWrite, forwarded interface{}, bookmark string, changed bool, err error) {
+	contentUUID := write.ContentUUID
+	if errors.Is(err, annotations.ErrStaleWrite) {
+		writeStale.Inc(1)
+		if !qh.forwardStale {
+			qh.log.WithMonitoringEvent("SaveNeo4j", tid, qh.messageType).WithUUID(contentUUID).
+				Warnf("%s older than the stored ones, skipped writing in Neo4j and forwarding", qh.messageType)
 			return
 		}
+		qh.log.WithMonitoringEvent("SaveNeo4j", tid, qh.messageType).WithUUID(contentUUID).
+			Warnf("%s older than the stored ones, skipped writing in Neo4j", qh.messageType)
+		qh.forward(tid, originSystem, bookmark, write, forwarded)
+		return
+	}
+	if err != nil {
+		qh.log.WithMonitoringEvent("SaveNeo4j", tid, qh.messageType).WithUUID(contentUUID).WithError(err).Error("Cannot write to Neo4j")
+		qh.sendToDeadLetter(message, stageWrite, err)
+		return
+	}
 
-		var annMsg map[string]interface{}
-		err = json.Unmarshal([]byte(message.Body), &annMsg)
-		if err != nil {
-			qh.log.WithTransactionID(tid).Error("Cannot process received message", tid)
+	if !changed {
+		qh.log.WithTransactionID(tid).WithUUID(contentUUID).Infof("%s unchanged, skipped writing in Neo4j", qh.messageType)
+		if !qh.forwardUnchanged {
 			return
 		}
+	} else {
+		qh.log.WithMonitoringEvent("SaveNeo4j", tid, qh.messageType).WithUUID(contentUUID).Infof("%s successfully written in Neo4j", qh.messageType)
+	}
+	qh.forward(tid, originSystem, bookmark, write, forwarded)
+}
 
-		contentUUID := annMsg[uuidMsgKey].(string)
-		var publication []interface{}
-		pubSlice, ok := annMsg[publicationMsgKey]
-		if ok {
-			publication, ok = pubSlice.([]interface{})
-			if !ok {
-				qh.log.Error("Publication field format is not supported")
-				return
-			}
-		}
+// forward sends the annotations of a message to the next queue, if there is one
+func (qh *queueHandler) forward(tid string, originSystem string, bookmark string, write annotations.ContentWrite, forwarded interface{}) {
+	if qh.forwarder == nil {
+		return
+	}
+	contentUUID := write.ContentUUID
+	stringPublication, err := convertPublicationToStringSlice(write.Publication)
+	if err != nil {
+		qh.log.WithError(err).WithTransactionID(tid).Warn("converting publication slice error, sending empty publication")
+	}
 
-		var bookmark string
-		if qh.messageType == "Annotations" {
-			err = qh.validate(annMsg[annotationsMsgKey])
-			if err != nil {
-				qh.log.WithError(err).Error("Validation error")
-				return
-			}
-			bookmark, err = qh.annotationsService.Write(contentUUID, lifecycle, platformVersion, publication, annMsg[annotationsMsgKey])
-		} else {
-			err = qh.validate(annMsg[suggestionsMsgKey])
-			if err != nil {
-				qh.log.WithError(err).Error("Validation error")
-				return
-			}
-			bookmark, err = qh.annotationsService.Write(contentUUID, lifecycle, platformVersion, publication, annMsg[suggestionsMsgKey])
-		}
+	qh.log.WithTransactionID(tid).WithUUID(contentUUID).Debug("Forwarding message to the next queue")
+	err = qh.forwarder.SendMessage(tid, originSystem, bookmark, write.PlatformVersion, contentUUID, forwarded, stringPublication)
+	if err != nil {
+		qh.log.WithError(err).WithUUID(contentUUID).WithTransactionID(tid).Error("Could not forward a message to kafka")
+	}
+}
 
-		if err != nil {
-			qh.log.WithMonitoringEvent("SaveNeo4j", tid, qh.messageType).WithUUID(contentUUID).WithError(err).Error("Cannot write to Neo4j")
-			return
-		}
+// writeOne writes the annotations of a single message
+func (qh *queueHandler) writeOne(tid string, write annotations.ContentWrite) (bookmark string, changed bool, err error) {
+	err = qh.write(tid, write.ContentUUID, func() error {
+		var err error
+		bookmark, changed, err = qh.annotationsService.Write(write.ContentUUID, write.Lifecycle, write.PlatformVersion, write.Publication, write.Annotations, write.LastModified, nil)
+		return err
+	})
+	return bookmark, changed, err
+}
 
-		qh.log.WithMonitoringEvent("SaveNeo4j", tid, qh.messageType).WithUUID(contentUUID).Infof("%s successfully written in Neo4j", qh.messageType)
+// writeBatch writes the annotations of many messages in a single transaction. When the transaction fails,
+// the messages are written one by one, so that only those which cannot be written fail.
+func (qh *queueHandler) writeBatch(batch []*batchedWrite) {
+	if len(batch) == 1 {
+		batch[0].bookmark, batch[0].changed, batch[0].err = qh.writeOne(batch[0].tid, batch[0].write)
+		return
+	}
 
-		stringPublication, err := convertPublicationToStringSlice(publication)
-		if err != nil {
-			qh.log.WithError(err).WithTransactionID(tid).Warn("converting publication slice error, sending empty publication")
+	batch = orderByContent(batch)
+	writes := make([]annotations.ContentWrite, 0, len(batch))
+	for _, w := range batch {
+		writes = append(writes, w.write)
+	}
+	bookmark, changed, err := qh.annotationsService.WriteBatch(writes)
+	if err == nil {
+		batchWrites.Inc(1)
+		batchedMessages.Inc(int64(len(batch)))
+		for i, w := range batch {
+			w.bookmark, w.changed = bookmark, changed[i]
 		}
+		return
+	}
 
-		//forward message to the next queue
-		if qh.forwarder != nil {
-			qh.log.WithTransactionID(tid).WithUUID(contentUUID).Debug("Forwarding message to the next queue")
-			err := qh.forwarder.SendMessage(tid, originSystem, bookmark, platformVersion, contentUUID, annMsg[annotationsMsgKey], stringPublication)
-			if err != nil {
-				qh.log.WithError(err).WithUUID(contentUUID).WithTransactionID(tid).Error("Could not forward a message to kafka")
-				return
-			}
-			return
-		}
+	batchFallbacks.Inc(1)
+	qh.log.WithError(err).Warnf("Could not write a batch of %d messages to Neo4j, writing them one by one", len(batch))
+	for _, w := range batch {
+		w.bookmark, w.changed, w.err = qh.writeOne(w.tid, w.write)
+	}
+}
+
+// write runs a write to Neo4j, retrying it according to the retry policy when it fails with a transient error
+func (qh *queueHandler) write(tid string, contentUUID string, write func() error) error {
+	retries := 0
+	err := qh.retry.run(write, func(retry int, wait time.Duration, err error) {
+		retries = retry
+		writeRetries.Inc(1)
+		qh.log.WithTransactionID(tid).WithUUID(contentUUID).WithError(err).Warnf("Transient error writing to Neo4j, retry %d of %d in %s", retry, qh.retry.maxRetries, wait)
 	})
+
+	switch {
+	case err == nil && retries > 0:
+		writeRetriesRecovered.Inc(1)
+	case err != nil && annotations.IsTransient(err):
+		writeRetriesExhausted.Inc(1)
+	case err != nil && !errors.Is(err, annotations.ErrStaleWrite):
+		writePermanentFailures.Inc(1)
+	}
+	return err
+}
+
+// lastModified returns when the annotations of a message were modified: the time of its Message-Timestamp header,
+// or else the lastModified field of its payload. The zero time, which skips the check for stale writes,
+// is returned when neither is set or readable.
+func (qh *queueHandler) lastModified(message kafka.FTMessage, annMsg map[string]interface{}) time.Time {
+	if header, found := message.Headers[messageTimestampHeader]; found {
+		t, err := parseMessageTimestamp(header)
+		if err == nil {
+			return t
+		}
+		qh.log.WithTransactionID(message.Headers[transactionidutils.TransactionIDHeader]).WithError(err).Warn("Ignoring unreadable Message-Timestamp header")
+	}
+	if field, ok := annMsg[lastModifiedKey].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, field)
+		if err == nil {
+			return t
+		}
+		qh.log.WithTransactionID(message.Headers[transactionidutils.TransactionIDHeader]).WithError(err).Warn("Ignoring unreadable lastModified field")
+	}
+	return time.Time{}
+}
+
+// parseMessageTimestamp reads a Message-Timestamp header, as set by the forwarder or in the RFC 3339 format
+func parseMessageTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(messageTimestampFormat, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// sendToDeadLetter publishes a message which cannot be processed to the dead-letter topic, if one is configured
+func (qh *queueHandler) sendToDeadLetter(message kafka.FTMessage, stage string, reason error) {
+	if qh.deadLetters == nil {
+		return
+	}
+	err := qh.deadLetters.send(message, stage, reason)
+	if err != nil {
+		qh.log.WithTransactionID(message.Headers[transactionidutils.TransactionIDHeader]).WithError(err).Error("Could not send a message to the dead-letter topic")
+	}
 }
 
 func (qh *queueHandler) getSourceFromHeader(originSystem string) (string, string, error) {
 	annotationLifecycle, found := qh.originMap[originSystem]
 	if !found {
-		return "", "", errors.Errorf("Annotation Lifecycle not found for origin system id: %s", originSystem)
+		return "", "", fmt.Errorf("Annotation Lifecycle not found for origin system id: %s", originSystem)
 	}
 
 	platformVersion, found := qh.lifecycleMap[annotationLifecycle]
 	if !found {
-		return "", "", errors.Errorf("Platform version not found for origin system id: %s and annotation lifecycle: %s", originSystem, annotationLifecycle)
+		return "", "", fmt.Errorf("Platform version not found for origin system id: %s and annotation lifecycle: %s", originSystem, annotationLifecycle)
 	}
 	return annotationLifecycle, platformVersion, nil
 }
 
-func (qh *queueHandler) validate(annotations interface{}) error {
-	for _, annotation := range annotations.([]interface{}) {
+func (qh *queueHandler) validate(key string, annotations interface{}) error {
+	list, ok := annotations.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s field is missing or is not a list", key)
+	}
+	for _, annotation := range list {
 		err := qh.validator.Validate(annotation)
 		if err != nil {
 			return err
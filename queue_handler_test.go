@@ -3,15 +3,21 @@ package main
 import (
 	"encoding/json"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/cm-annotations-ontology/validator"
 	"github.com/Financial-Times/kafka-client-go/v3"
 
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
 	"github.com/Financial-Times/annotations-rw-neo4j/v4/forwarder"
 
 	logger "github.com/Financial-Times/go-logger/v2"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -32,6 +38,7 @@ type QueueHandlerTestSuite struct {
 	log                *logger.UPPLogger
 	validator          jsonValidator
 	publication        []string
+	lastModified       time.Time
 }
 
 func (suite *QueueHandlerTestSuite) SetupTest() {
@@ -47,6 +54,8 @@ func (suite *QueueHandlerTestSuite) SetupTest() {
 	suite.body, err = os.ReadFile("exampleAnnotationsMessage.json")
 	assert.NoError(suite.T(), err, "Unexpected error")
 	suite.message = kafka.NewFTMessage(suite.headers, string(suite.body))
+	suite.lastModified, err = time.Parse(messageTimestampFormat, suite.headers["Message-Timestamp"])
+	assert.NoError(suite.T(), err, "Unexpected error")
 	err = json.Unmarshal(suite.body, &suite.queueMessage)
 	assert.NoError(suite.T(), err, "Unexpected error")
 	suite.annotationsService = new(mockAnnotationsService)
@@ -63,7 +72,7 @@ func TestQueueHandlerTestSuite(t *testing.T) {
 }
 
 func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest() {
-	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey]).Return(suite.bookmark, nil)
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return(suite.bookmark, true, nil)
 	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil)
 
 	qh := &queueHandler{
@@ -78,12 +87,53 @@ func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest() {
 	}
 	qh.Ingest()
 
-	suite.annotationsService.AssertCalled(suite.T(), "Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey])
+	suite.annotationsService.AssertCalled(suite.T(), "Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil))
 	suite.forwarder.AssertCalled(suite.T(), "SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication)
 }
 
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_ProcessesMessagesOfAPartitionConcurrently() {
+	other, otherUUID := suite.otherContentMessage()
+	otherStarted := make(chan struct{})
+	concurrent := false
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).
+		Run(func(mock.Arguments) {
+			// the write of the first message only completes once the write of the next message has started
+			select {
+			case <-otherStarted:
+				concurrent = true
+			case <-time.After(5 * time.Second):
+			}
+		}).Return(suite.bookmark, true, nil)
+	suite.annotationsService.On("Write", otherUUID, annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).
+		Run(func(mock.Arguments) { close(otherStarted) }).Return(suite.bookmark, true, nil)
+	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, mock.Anything, suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil)
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+	}
+	qh.workers = newContentWorkers(16, 10)
+	require.NotEqual(suite.T(), qh.workers.worker(suite.queueMessage[uuidMsgKey].(string)), qh.workers.worker(otherUUID), "the test needs content handed to different workers")
+	consumer := newTestOrderedConsumer(10, qh.dispatch)
+	session := &fakeSession{}
+
+	// both messages come from the same partition
+	err := consumer.ConsumeClaim(session, newFakeClaim(suite.message, other))
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), concurrent, "the messages have not been processed concurrently")
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Write", 2)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 2)
+	assert.Equal(suite.T(), []int64{0, 1}, session.markedOffsets(), "the offsets are marked in order once the messages have been processed")
+}
+
 func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_ProducerNil() {
-	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey]).Return(suite.bookmark, nil)
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return(suite.bookmark, true, nil)
 
 	qh := queueHandler{
 		validator:          suite.validator,
@@ -97,7 +147,27 @@ func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_ProducerNil() {
 	}
 	qh.Ingest()
 
-	suite.annotationsService.AssertCalled(suite.T(), "Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey])
+	suite.annotationsService.AssertCalled(suite.T(), "Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil))
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_UnchangedNotForwarded() {
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return(suite.bookmark, false, nil)
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		consumer:           mockConsumer{message: suite.message},
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+		forwardUnchanged:   false,
+	}
+	qh.Ingest()
+
+	suite.annotationsService.AssertCalled(suite.T(), "Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil))
 	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
 }
 
@@ -138,3 +208,311 @@ func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_InvalidOrigin() {
 	// if message is valid, the first method to be called is annotationsService.Write
 	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Write", 0)
 }
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_DeadLetters() {
+	missingTID := map[string]string{"Origin-System-Id": suite.originSystem}
+	tests := map[string]struct {
+		message  kafka.FTMessage
+		stage    string
+		writeErr error
+	}{
+		"missing transaction id": {kafka.NewFTMessage(missingTID, string(suite.body)), stageHeaders, nil},
+		"invalid json":           {kafka.NewFTMessage(suite.headers, "invalid json"), stageUnmarshal, nil},
+		"invalid annotations":    {kafka.NewFTMessage(suite.headers, `{"uuid":"12345","annotations":[{"predicate":"likes"}]}`), stageValidation, nil},
+		"missing annotations":    {kafka.NewFTMessage(suite.headers, `{"uuid":"12345"}`), stageValidation, nil},
+		"annotations not a list": {kafka.NewFTMessage(suite.headers, `{"uuid":"12345","annotations":{"predicate":"about"}}`), stageValidation, nil},
+		"failed write":           {suite.message, stageWrite, errors.New("neo4j is down")},
+	}
+	for name, test := range tests {
+		annotationsService := new(mockAnnotationsService)
+		annotationsService.On("Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("", false, test.writeErr)
+		producer := new(mockProducer)
+		var deadLettered kafka.FTMessage
+		producer.On("SendMessage", mock.Anything).Run(func(args mock.Arguments) {
+			deadLettered = args.Get(0).(kafka.FTMessage)
+		}).Return(nil).Once()
+
+		qh := &queueHandler{
+			validator:          suite.validator,
+			annotationsService: annotationsService,
+			consumer:           mockConsumer{message: test.message},
+			forwarder:          suite.forwarder,
+			originMap:          suite.originMap,
+			lifecycleMap:       suite.lifecycleMap,
+			messageType:        suite.messageType,
+			log:                suite.log,
+			deadLetters:        newDeadLetterQueue(producer),
+		}
+		qh.Ingest()
+
+		producer.AssertExpectations(suite.T())
+		assert.Equal(suite.T(), test.message.Body, deadLettered.Body, "Wrong body for %s", name)
+		for header, value := range test.message.Headers {
+			assert.Equal(suite.T(), value, deadLettered.Headers[header], "Wrong %s header for %s", header, name)
+		}
+		assert.Equal(suite.T(), test.stage, deadLettered.Headers[deadLetterStageHeader], "Wrong stage for %s", name)
+		assert.NotEmpty(suite.T(), deadLettered.Headers[deadLetterReasonHeader], "Missing reason for %s", name)
+		assert.NotEmpty(suite.T(), deadLettered.Headers[deadLetterTimestampHeader], "Missing timestamp for %s", name)
+	}
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_RetriesTransientWriteErrors() {
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return("", false, errTransient).Twice()
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return(suite.bookmark, true, nil).Once()
+	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil)
+	retries, recovered := writeRetries.Count(), writeRetriesRecovered.Count()
+
+	var waits []time.Duration
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		consumer:           mockConsumer{message: suite.message},
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+		retry:              testRetryPolicy(3, &waits),
+	}
+	qh.Ingest()
+
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Write", 3)
+	suite.forwarder.AssertExpectations(suite.T())
+	assert.Len(suite.T(), waits, 2)
+	assert.Equal(suite.T(), retries+2, writeRetries.Count())
+	assert.Equal(suite.T(), recovered+1, writeRetriesRecovered.Count())
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_DoesNotRetryPermanentWriteErrors() {
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return("", false, errors.New("Neo4jError: Neo.ClientError.Schema.ConstraintValidationFailed (exists)"))
+	permanent := writePermanentFailures.Count()
+
+	var waits []time.Duration
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		consumer:           mockConsumer{message: suite.message},
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+		retry:              testRetryPolicy(3, &waits),
+	}
+	qh.Ingest()
+
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Write", 1)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+	assert.Empty(suite.T(), waits)
+	assert.Equal(suite.T(), permanent+1, writePermanentFailures.Count())
+}
+
+// otherContentMessage returns the test message for another piece of content, along with its uuid
+func (suite *QueueHandlerTestSuite) otherContentMessage() (kafka.FTMessage, string) {
+	otherUUID := "0f3b5b2c-9e4a-4a3c-8f51-0b4d2c1e6a77"
+	var body map[string]interface{}
+	assert.NoError(suite.T(), json.Unmarshal(suite.body, &body))
+	body[uuidMsgKey] = otherUUID
+	b, err := json.Marshal(body)
+	assert.NoError(suite.T(), err)
+	return kafka.NewFTMessage(suite.headers, string(b)), otherUUID
+}
+
+// handleConcurrently processes messages at the same time, as the consumer does for messages from different partitions
+func handleConcurrently(qh *queueHandler, messages ...kafka.FTMessage) {
+	var wg sync.WaitGroup
+	for _, message := range messages {
+		wg.Add(1)
+		go func(message kafka.FTMessage) {
+			defer wg.Done()
+			qh.handleMessage(message)
+		}(message)
+	}
+	wg.Wait()
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_BatchesWrites() {
+	other, otherUUID := suite.otherContentMessage()
+	suite.annotationsService.On("WriteBatch", mock.MatchedBy(func(writes []annotations.ContentWrite) bool {
+		return len(writes) == 2
+	})).Return(suite.bookmark, []bool{true, true}, nil)
+	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, mock.Anything, suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil)
+	batches := batchWrites.Count()
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+	}
+	qh.batches = newWriteBatcher(2, time.Minute, qh.writeBatch)
+	handleConcurrently(qh, suite.message, other)
+
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "WriteBatch", 1)
+	suite.annotationsService.AssertNotCalled(suite.T(), "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.forwarder.AssertCalled(suite.T(), "SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication)
+	suite.forwarder.AssertCalled(suite.T(), "SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, otherUUID, suite.queueMessage[annotationsMsgKey], suite.publication)
+	assert.Equal(suite.T(), batches+1, batchWrites.Count())
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_BatchesWritesWithoutBlockingTheConsumer() {
+	suite.annotationsService.On("WriteBatch", mock.MatchedBy(func(writes []annotations.ContentWrite) bool {
+		return len(writes) == 2
+	})).Return(suite.bookmark, []bool{false, true}, nil)
+	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil)
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+	}
+	// a single worker handles both messages, which it could not batch if it waited for them to be written
+	qh.workers = newContentWorkers(1, 10)
+	qh.batches = newWriteBatcher(2, time.Minute, qh.writeBatch)
+	consumer := newTestOrderedConsumer(10, qh.dispatch)
+	session := &fakeSession{}
+
+	err := consumer.ConsumeClaim(session, newFakeClaim(suite.message, suite.message))
+
+	assert.NoError(suite.T(), err)
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "WriteBatch", 1)
+	// the batch only writes the latest message for the content, the other one is unchanged
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 1)
+	assert.Equal(suite.T(), []int64{0, 1}, session.markedOffsets())
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_DoesNotForwardUnchangedBatchedWrites() {
+	other, _ := suite.otherContentMessage()
+	suite.annotationsService.On("WriteBatch", mock.Anything).Return(suite.bookmark, []bool{false, false}, nil)
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+	}
+	qh.batches = newWriteBatcher(2, time.Minute, qh.writeBatch)
+	handleConcurrently(qh, suite.message, other)
+
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "WriteBatch", 1)
+	suite.forwarder.AssertNotCalled(suite.T(), "SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_FailedBatchIsolatesBadMessages() {
+	other, otherUUID := suite.otherContentMessage()
+	suite.annotationsService.On("WriteBatch", mock.Anything).Return("", nil, errors.New("Neo4jError: Neo.ClientError.Schema.ConstraintValidationFailed (exists)"))
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return(suite.bookmark, true, nil)
+	suite.annotationsService.On("Write", otherUUID, annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return("", false, errors.New("Neo4jError: Neo.ClientError.Schema.ConstraintValidationFailed (exists)"))
+	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, suite.bookmark, platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil)
+	producer := new(mockProducer)
+	producer.On("SendMessage", mock.Anything).Return(nil)
+	fallbacks := batchFallbacks.Count()
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+		deadLetters:        newDeadLetterQueue(producer),
+	}
+	qh.batches = newWriteBatcher(2, time.Minute, qh.writeBatch)
+	handleConcurrently(qh, suite.message, other)
+
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Write", 2)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 1)
+	producer.AssertNumberOfCalls(suite.T(), "SendMessage", 1)
+	dead := producer.Calls[0].Arguments.Get(0).(kafka.FTMessage)
+	assert.Equal(suite.T(), other.Body, dead.Body)
+	assert.Equal(suite.T(), stageWrite, dead.Headers[deadLetterStageHeader])
+	assert.Equal(suite.T(), fallbacks+1, batchFallbacks.Count())
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_SkipsStaleMessages() {
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return("", false, annotations.ErrStaleWrite)
+	producer := new(mockProducer)
+	stale, permanent := writeStale.Count(), writePermanentFailures.Count()
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		consumer:           mockConsumer{message: suite.message},
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+		deadLetters:        newDeadLetterQueue(producer),
+	}
+	qh.Ingest()
+
+	suite.annotationsService.AssertNumberOfCalls(suite.T(), "Write", 1)
+	suite.forwarder.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+	producer.AssertNumberOfCalls(suite.T(), "SendMessage", 0)
+	assert.Equal(suite.T(), stale+1, writeStale.Count())
+	assert.Equal(suite.T(), permanent, writePermanentFailures.Count())
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_ForwardsStaleMessagesWhenConfigured() {
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], suite.lastModified, []string(nil)).Return("", false, annotations.ErrStaleWrite)
+	suite.forwarder.On("SendMessage", suite.tid, suite.originSystem, "", platformVersion, suite.queueMessage[uuidMsgKey], suite.queueMessage[annotationsMsgKey], suite.publication).Return(nil).Once()
+	stale := writeStale.Count()
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		consumer:           mockConsumer{message: suite.message},
+		forwarder:          suite.forwarder,
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+		forwardStale:       true,
+	}
+	qh.Ingest()
+
+	suite.forwarder.AssertExpectations(suite.T())
+	assert.Equal(suite.T(), stale+1, writeStale.Count())
+}
+
+func (suite *QueueHandlerTestSuite) TestQueueHandler_Ingest_LastModifiedFromPayload() {
+	var body map[string]interface{}
+	assert.NoError(suite.T(), json.Unmarshal(suite.body, &body))
+	body[lastModifiedKey] = "2024-03-01T10:15:30.123Z"
+	b, err := json.Marshal(body)
+	assert.NoError(suite.T(), err)
+	headers := map[string]string{}
+	for name, value := range suite.headers {
+		if name != messageTimestampHeader {
+			headers[name] = value
+		}
+	}
+	lastModified := time.Date(2024, 3, 1, 10, 15, 30, 123000000, time.UTC)
+	suite.annotationsService.On("Write", suite.queueMessage[uuidMsgKey], annotationLifecycle, platformVersion, []interface{}{"8e6c705e-1132-42a2-8db0-c295e29e8658"}, suite.queueMessage[annotationsMsgKey], lastModified, []string(nil)).Return(suite.bookmark, true, nil)
+
+	qh := &queueHandler{
+		validator:          suite.validator,
+		annotationsService: suite.annotationsService,
+		consumer:           mockConsumer{message: kafka.NewFTMessage(headers, string(b))},
+		originMap:          suite.originMap,
+		lifecycleMap:       suite.lifecycleMap,
+		messageType:        suite.messageType,
+		log:                suite.log,
+	}
+	qh.Ingest()
+
+	suite.annotationsService.AssertExpectations(suite.T())
+}
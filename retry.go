@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// retryPolicy retries the operations failing with a transient error, waiting for an exponential backoff with jitter
+// between attempts. Its zero value never retries.
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	isTransient    func(error) bool
+	sleep          func(time.Duration)
+	// jitter returns a random number in [0, 1)
+	jitter func() float64
+}
+
+func newRetryPolicy(maxRetries int, initialBackoff time.Duration, maxBackoff time.Duration, isTransient func(error) bool) retryPolicy {
+	return retryPolicy{
+		maxRetries:     maxRetries,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		isTransient:    isTransient,
+		sleep:          time.Sleep,
+		jitter:         rand.Float64,
+	}
+}
+
+// backoff returns the wait before a retry, counting from 1. The backoff doubles with every retry up to maxBackoff,
+// and only half of it is fixed so that the consumers failing at the same time do not all retry at the same time.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	backoff := p.maxBackoff
+	if retry < 32 && p.initialBackoff<<(retry-1) < p.maxBackoff {
+		backoff = p.initialBackoff << (retry - 1)
+	}
+	return backoff/2 + time.Duration(p.jitter()*float64(backoff/2))
+}
+
+// run calls op until it succeeds, fails with a permanent error or has been retried maxRetries times, returning its
+// last error. onRetry is called before waiting for each retry.
+func (p retryPolicy) run(op func() error, onRetry func(retry int, wait time.Duration, err error)) error {
+	for retry := 1; ; retry++ {
+		err := op()
+		if err == nil || retry > p.maxRetries || p.isTransient == nil || !p.isTransient(err) {
+			return err
+		}
+
+		wait := p.backoff(retry)
+		onRetry(retry, wait, err)
+		p.sleep(wait)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-rw-neo4j/v4/annotations"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTransient = &neo4j.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader", Msg: "No write operations are allowed on this database"}
+
+func testRetryPolicy(maxRetries int, waits *[]time.Duration) retryPolicy {
+	p := newRetryPolicy(maxRetries, 100*time.Millisecond, time.Second, annotations.IsTransient)
+	p.sleep = func(wait time.Duration) { *waits = append(*waits, wait) }
+	p.jitter = func() float64 { return 0.5 }
+	return p
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	var waits []time.Duration
+	p := testRetryPolicy(6, &waits)
+	for retry, expected := range []time.Duration{75, 150, 300, 600, 750, 750} {
+		assert.Equal(t, expected*time.Millisecond, p.backoff(retry+1), "Wrong backoff for retry %d", retry+1)
+	}
+
+	p.jitter = func() float64 { return 0 }
+	assert.Equal(t, 50*time.Millisecond, p.backoff(1), "Half of the backoff should not depend on the jitter")
+	assert.Equal(t, 500*time.Millisecond, p.backoff(100), "The backoff should not overflow")
+}
+
+func TestRetryPolicyRetriesTransientErrors(t *testing.T) {
+	var waits []time.Duration
+	p := testRetryPolicy(3, &waits)
+	calls := 0
+	var retries []int
+	err := p.run(func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("executing write queries in neo4j failed: %w", errTransient)
+		}
+		return nil
+	}, func(retry int, _ time.Duration, _ error) { retries = append(retries, retry) })
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, retries)
+	assert.Equal(t, []time.Duration{75 * time.Millisecond, 150 * time.Millisecond}, waits)
+}
+
+func TestRetryPolicyGivesUp(t *testing.T) {
+	var waits []time.Duration
+	p := testRetryPolicy(2, &waits)
+	calls := 0
+	err := p.run(func() error {
+		calls++
+		return io.EOF
+	}, func(int, time.Duration, error) {})
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 3, calls, "The first attempt should be retried maxRetries times")
+
+	calls = 0
+	err = p.run(func() error {
+		calls++
+		return annotations.ErrUnknownPredicate
+	}, func(int, time.Duration, error) {})
+	assert.ErrorIs(t, err, annotations.ErrUnknownPredicate)
+	assert.Equal(t, 1, calls, "Permanent errors should not be retried")
+
+	calls = 0
+	err = retryPolicy{}.run(func() error {
+		calls++
+		return io.EOF
+	}, func(int, time.Duration, error) {})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "The zero policy should not retry")
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := map[error]bool{
+		nil:          false,
+		errTransient: true,
+		&neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected", Msg: "deadlock"}:                                          true,
+		fmt.Errorf("executing write queries in neo4j failed: %w", &neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"}): true,
+		fmt.Errorf("reading stored annotations failed: %w", io.ErrUnexpectedEOF):                                                             true,
+		&neo4j.ConnectivityError{}:     true,
+		annotations.ErrConcurrentWrite: true,
+		&neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.Terminated", Msg: "terminated"}:     false,
+		&neo4j.Neo4jError{Code: "Neo.ClientError.Schema.ConstraintValidationFailed", Msg: "exists"}: false,
+		errors.New("Neo4jError: Neo.TransientError.Transaction.DeadlockDetected (deadlock)"):        false,
+		annotations.ErrUnknownPredicate: false,
+	}
+	for err, expected := range tests {
+		assert.Equal(t, expected, annotations.IsTransient(err), "Wrong classification of %T", err)
+	}
+}
+
+func TestGetMetricsServesRetryCounters(t *testing.T) {
+	writeRetries.Inc(1)
+	rec := httptest.NewRecorder()
+	GetMetrics(rec, httptest.NewRequest("GET", "/__metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "Wrong response code")
+
+	var result map[string]map[string]float64
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, float64(writeRetries.Count()), result["consumer.neo4j.write.retries"]["count"])
+	assert.Contains(t, result, "consumer.neo4j.write.exhausted")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"hash/fnv"
+)
+
+// contentWorkers runs tasks on a fixed number of workers, handing all the tasks of a piece of content to the same
+// worker so that they run in the order they are handed over, while the tasks of different content run in parallel.
+// Handing a task over only blocks while the queue of its worker is full.
+type contentWorkers struct {
+	queues []chan func()
+}
+
+func newContentWorkers(workers int, queueSize int) *contentWorkers {
+	w := &contentWorkers{queues: make([]chan func(), workers)}
+	for i := range w.queues {
+		w.queues[i] = make(chan func(), queueSize)
+		go func(queue chan func()) {
+			for task := range queue {
+				task()
+			}
+		}(w.queues[i])
+	}
+	return w
+}
+
+// run hands a task to the worker of a piece of content, without waiting for it to run
+func (w *contentWorkers) run(key string, task func()) {
+	w.queues[w.worker(key)] <- task
+}
+
+func (w *contentWorkers) worker(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(w.queues)))
+}
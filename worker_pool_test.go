@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentWorkersRunDifferentContentInParallel(t *testing.T) {
+	first := "a7732a22-3884-4bfe-9761-fef161e41d69"
+	second := "0f3b5b2c-9e4a-4a3c-8f51-0b4d2c1e6a77"
+	workers := newContentWorkers(16, 1)
+	require.NotEqual(t, workers.worker(first), workers.worker(second), "the test needs content handed to different workers")
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	workers.run(first, func() {
+		// blocks until the task of the other content is running
+		<-started
+		close(done)
+	})
+	workers.run(second, func() {
+		close(started)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tasks have not run in parallel")
+	}
+}
+
+func TestContentWorkersRunSameContentInOrder(t *testing.T) {
+	content := "a7732a22-3884-4bfe-9761-fef161e41d69"
+	workers := newContentWorkers(4, 2)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		i := i
+		workers.run(content, func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order, "tasks for the same content must run in the order they are handed over")
+}